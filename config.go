@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/api"
+	"github.com/kunalsin9h/upkube/internal/config"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/pkg/errors"
+)
+
+// fromFile returns the config file value of a setting, unless its env var is set.
+// value holds the env var or the default, as set by init.
+func fromFile(name, value, fileValue string) string {
+	if os.Getenv(name) == "" && fileValue != "" {
+		return fileValue
+	}
+	return value
+}
+
+// applyConfigFile sets the settings only applied on startup from the config file.
+func applyConfigFile(file *config.File) {
+	UPKUBE_HOST = fromFile("UPKUBE_HOST", UPKUBE_HOST, file.Host)
+	UPKUBE_PORT = fromFile("UPKUBE_PORT", UPKUBE_PORT, file.Port)
+	UPKUBE_ENV = fromFile("UPKUBE_ENV", UPKUBE_ENV, file.Env)
+	UPKUBE_DB_PATH = fromFile("UPKUBE_DB_PATH", UPKUBE_DB_PATH, file.DBPath)
+	UPKUBE_KUBE_CONTEXT = fromFile("UPKUBE_KUBE_CONTEXT", UPKUBE_KUBE_CONTEXT, file.KubeContext)
+	UPKUBE_MASTER_URL = fromFile("UPKUBE_MASTER_URL", UPKUBE_MASTER_URL, file.MasterURL)
+	UPKUBE_CF_TEAM_DOMAIN = fromFile("UPKUBE_CF_TEAM_DOMAIN", UPKUBE_CF_TEAM_DOMAIN, file.CloudflareAccess.TeamDomain)
+	UPKUBE_CF_AUDIENCE = fromFile("UPKUBE_CF_AUDIENCE", UPKUBE_CF_AUDIENCE, file.CloudflareAccess.Audience)
+	UPKUBE_CF_CERTS_URL = fromFile("UPKUBE_CF_CERTS_URL", UPKUBE_CF_CERTS_URL, file.CloudflareAccess.CertsURL)
+	if file.Impersonate != nil {
+		UPKUBE_IMPERSONATE = fromFile("UPKUBE_IMPERSONATE", UPKUBE_IMPERSONATE, strconv.FormatBool(*file.Impersonate))
+	}
+}
+
+// newSettings parses the settings a config file reload applies, from their env vars or else the config file.
+// The policy of UPKUBE_POLICY_FILE is read again on each reload too.
+func newSettings(file *config.File) (api.Settings, error) {
+	var settings api.Settings
+	var err error
+
+	settings.ProtectedNamespaces = splitList(fromFile("UPKUBE_PROTECTED_NAMESPACES", UPKUBE_PROTECTED_NAMESPACES, strings.Join(file.ProtectedNamespaces, ",")))
+
+	requestTTL := fromFile("UPKUBE_REQUEST_TTL", UPKUBE_REQUEST_TTL, file.RequestTTL)
+	if settings.RequestTTL, err = time.ParseDuration(requestTTL); err != nil {
+		return settings, errors.Wrapf(err, "invalid request TTL %q", requestTTL)
+	}
+
+	settings.Policy = file.Policy
+	if UPKUBE_POLICY_FILE != "" {
+		if settings.Policy, err = policy.Load(UPKUBE_POLICY_FILE); err != nil {
+			return settings, err
+		}
+	}
+
+	scaleBounds := fromFile("UPKUBE_SCALE_BOUNDS", UPKUBE_SCALE_BOUNDS, strings.Join(file.ScaleBounds, ","))
+	if settings.ScaleBounds, err = policy.ParseScaleBounds(scaleBounds); err != nil {
+		return settings, errors.Wrap(err, "invalid scale bounds")
+	}
+
+	settings.GuardedNamespaces = splitList(fromFile("UPKUBE_GUARDED_NAMESPACES", UPKUBE_GUARDED_NAMESPACES, strings.Join(file.GuardedNamespaces, ",")))
+
+	guardWindow := fromFile("UPKUBE_GUARD_WINDOW", UPKUBE_GUARD_WINDOW, file.GuardWindow)
+	if settings.GuardWindow, err = time.ParseDuration(guardWindow); err != nil {
+		return settings, errors.Wrapf(err, "invalid guard window %q", guardWindow)
+	}
+
+	settings.RegistryWebhookSecret = fromFile("UPKUBE_REGISTRY_WEBHOOK_SECRET", UPKUBE_REGISTRY_WEBHOOK_SECRET, file.RegistryWebhookSecret)
+	plainSecret := UPKUBE_REGISTRY_WEBHOOK_PLAIN_SECRET
+	if file.RegistryWebhookPlainSecret != nil {
+		plainSecret = fromFile("UPKUBE_REGISTRY_WEBHOOK_PLAIN_SECRET", plainSecret, strconv.FormatBool(*file.RegistryWebhookPlainSecret))
+	}
+	settings.RegistryWebhookPlainSecret = strings.EqualFold(plainSecret, "true")
+
+	settings.Namespaces = splitList(fromFile("UPKUBE_NAMESPACES", UPKUBE_NAMESPACES, strings.Join(file.Namespaces, ",")))
+	include := splitList(fromFile("UPKUBE_INCLUDE_NAMESPACES", UPKUBE_INCLUDE_NAMESPACES, strings.Join(file.IncludeNamespaces, ",")))
+	exclude := splitList(fromFile("UPKUBE_EXCLUDE_NAMESPACES", UPKUBE_EXCLUDE_NAMESPACES, strings.Join(file.ExcludeNamespaces, ",")))
+	if settings.NamespaceFilter, err = policy.NewNamespaceFilter(include, exclude); err != nil {
+		return settings, errors.Wrap(err, "invalid namespace filter")
+	}
+
+	managedOnly := UPKUBE_MANAGED_ONLY
+	if file.ManagedOnly != nil {
+		managedOnly = fromFile("UPKUBE_MANAGED_ONLY", managedOnly, strconv.FormatBool(*file.ManagedOnly))
+	}
+	settings.ManagedOnly = strings.EqualFold(managedOnly, "true")
+
+	return settings, nil
+}
+
+// watchConfigFile reloads the settings of serverConfig when the config file changes.
+// An invalid file is logged and ignored, the server keeps the settings it has.
+func watchConfigFile(serverConfig *api.ServerConfig, startup *config.File, stop <-chan struct{}) error {
+	return config.Watch(UPKUBE_CONFIG_FILE, stop, func(file *config.File, err error) {
+		if err != nil {
+			log.Errorf("Failed to reload config, keeping the current one: %v", err)
+			return
+		}
+
+		settings, err := newSettings(file)
+		if err != nil {
+			log.Errorf("Failed to reload config, keeping the current one: %v", err)
+			return
+		}
+
+		if file.RestartRequired(startup) {
+			log.Warn("Config file changed settings only applied on startup, restart upkube to apply them")
+		}
+
+		serverConfig.Reload(settings)
+		log.Infof("Reloaded config from %s", UPKUBE_CONFIG_FILE)
+	})
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The actions below are shared by the HTML forms and the JSON API, so both authorize and record the same way.
+// They return an *actionError for failures with a status code other than 500.
+
+// actionError is an error answered with Status.
+type actionError struct {
+	Status  int
+	Message string
+}
+
+func (e *actionError) Error() string {
+	return e.Message
+}
+
+func newActionError(status int, format string, args ...any) error {
+	return &actionError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+func errForbidden(namespace string, verb policy.Verb) error {
+	return newActionError(http.StatusForbidden, "Forbidden: you are not allowed to %s in namespace %s.", verb, namespace)
+}
+
+func errForbiddenWorkload(namespace, name string, verb policy.Verb) error {
+	return newActionError(http.StatusForbidden, "Forbidden: you are not allowed to %s %s in namespace %s.", verb, name, namespace)
+}
+
+// errorStatus returns the status code and message an error is answered with.
+// Kubernetes errors keep their status, e.g. a workload that does not exist is a 404.
+func errorStatus(err error) (int, string) {
+	var actionErr *actionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Status, actionErr.Message
+	}
+	if status, ok := errors.Cause(err).(apierrors.APIStatus); ok && status.Status().Code >= 400 {
+		return int(status.Status().Code), err.Error()
+	}
+
+	return http.StatusInternalServerError, err.Error()
+}
+
+// writeError answers an action error as plain text, for the HTML forms.
+func writeError(w http.ResponseWriter, err error) {
+	status, message := errorStatus(err)
+	http.Error(w, message, status)
+}
+
+func (c *ServerConfig) restartWorkload(r *http.Request, kind kubeapi.WorkloadKind, namespace, name string) error {
+	if kind == kubeapi.KindCronJob {
+		return newActionError(http.StatusBadRequest, "Bad Request: CronJobs can not be restarted, run them instead.")
+	}
+	if !c.allowedWorkload(r, namespace, name, policy.VerbRestart) {
+		return errForbiddenWorkload(namespace, name, policy.VerbRestart)
+	}
+
+	clientSet, err := c.clientSetFor(r)
+	if err != nil {
+		return err
+	}
+	if err := c.checkWorkloadAction(clientSet, kind, namespace, name, kubeapi.AllowRestartAnnotation); err != nil {
+		return err
+	}
+
+	err = kubeapi.RestartWorkload(clientSet, kind, namespace, name)
+	c.recordActivity(store.Activity{
+		User:       identityFrom(r).Email,
+		Action:     store.ActionRestart,
+		Cluster:    c.clusterFrom(r).Name,
+		Namespace:  namespace,
+		Kind:       string(kind),
+		Deployment: name,
+	}, err)
+	if err != nil {
+		return errors.Wrap(err, "Failed to restart "+string(kind))
+	}
+
+	return nil
+}
+
+// updateWorkloadImage updates the image of a container, in protected namespaces it returns the change request
+// created instead.
+func (c *ServerConfig) updateWorkloadImage(r *http.Request, kind kubeapi.WorkloadKind, namespace, name, container, oldImage, newImage string) (*store.ChangeRequest, error) {
+	if !c.allowedWorkload(r, namespace, name, policy.VerbUpdateImage) {
+		return nil, errForbiddenWorkload(namespace, name, policy.VerbUpdateImage)
+	}
+
+	cluster := c.clusterFrom(r)
+	clientSet, err := c.clientSetIn(r, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.applyImageUpdate(cluster, clientSet, kind, namespace, name, container, oldImage, newImage, identityFrom(r).Email)
+}
+
+// applyImageUpdate updates the image as userEmail once they are authorized, or requests the change in protected namespaces.
+func (c *ServerConfig) applyImageUpdate(cluster *kubeapi.Cluster, clientSet *kubernetes.Clientset, kind kubeapi.WorkloadKind, namespace, name, container, oldImage, newImage, userEmail string) (*store.ChangeRequest, error) {
+	if err := c.checkWorkloadAction(clientSet, kind, namespace, name, kubeapi.AllowImageUpdateAnnotation); err != nil {
+		return nil, err
+	}
+
+	if c.isProtected(namespace) {
+		return c.createChangeRequest(store.ChangeRequest{
+			RequestedBy: userEmail,
+			Cluster:     cluster.Name,
+			Namespace:   namespace,
+			Kind:        string(kind),
+			Deployment:  name,
+			Container:   container,
+			OldImage:    oldImage,
+			NewImage:    newImage,
+		})
+	}
+
+	err := c.updateImage(cluster, clientSet, kind, namespace, name, container, oldImage, newImage, userEmail)
+	c.recordActivity(store.Activity{
+		User:       userEmail,
+		Action:     store.ActionUpdateImage,
+		Cluster:    cluster.Name,
+		Namespace:  namespace,
+		Kind:       string(kind),
+		Deployment: name,
+		Container:  container,
+		OldImage:   oldImage,
+		NewImage:   newImage,
+	}, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to update image")
+	}
+
+	return nil, nil
+}
+
+// scaleDeployment changes the replicas of a Deployment, within the bounds of its namespace.
+// Deployments targeted by an HPA are not scaled, since the autoscaler would revert it.
+func (c *ServerConfig) scaleDeployment(r *http.Request, namespace, name string, replicas int32) error {
+	if !c.allowedWorkload(r, namespace, name, policy.VerbScale) {
+		return errForbiddenWorkload(namespace, name, policy.VerbScale)
+	}
+	if err := c.settings().ScaleBounds.For(namespace).Check(replicas); err != nil {
+		return newActionError(http.StatusBadRequest, "Bad Request: %v", err)
+	}
+
+	clientSet, err := c.clientSetFor(r)
+	if err != nil {
+		return err
+	}
+	if err := c.checkWorkloadAction(clientSet, kubeapi.KindDeployment, namespace, name, ""); err != nil {
+		return err
+	}
+
+	autoscaler, err := kubeapi.GetDeploymentHPA(clientSet, namespace, name)
+	if err != nil {
+		return errors.Wrap(err, "Cannot scale, failed to check the deployment is not scaled by a HorizontalPodAutoscaler")
+	}
+	if autoscaler != "" {
+		return newActionError(http.StatusConflict, "Conflict: deployment is scaled by the HorizontalPodAutoscaler %s, change its min/max replicas instead.", autoscaler)
+	}
+
+	oldReplicas, err := kubeapi.ScaleDeployment(clientSet, namespace, name, replicas)
+	activity := store.Activity{
+		User:        identityFrom(r).Email,
+		Action:      store.ActionScale,
+		Cluster:     c.clusterFrom(r).Name,
+		Namespace:   namespace,
+		Kind:        string(kubeapi.KindDeployment),
+		Deployment:  name,
+		NewReplicas: &replicas,
+	}
+	if err == nil {
+		activity.OldReplicas = &oldReplicas
+	}
+	c.recordActivity(activity, err)
+	if err != nil {
+		return errors.Wrap(err, "Failed to scale deployment")
+	}
+
+	return nil
+}
+
+// rollbackDeployment restores the pod template of a previous revision. Rollbacks change images,
+// so they need the same verb and the same approval as image updates.
+func (c *ServerConfig) rollbackDeployment(r *http.Request, namespace, name string, revision int64) (*store.ChangeRequest, error) {
+	if !c.allowedWorkload(r, namespace, name, policy.VerbUpdateImage) {
+		return nil, errForbiddenWorkload(namespace, name, policy.VerbUpdateImage)
+	}
+	userEmail := identityFrom(r).Email
+
+	clientSet, err := c.clientSetFor(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkWorkloadAction(clientSet, kubeapi.KindDeployment, namespace, name, kubeapi.AllowImageUpdateAnnotation); err != nil {
+		return nil, err
+	}
+
+	// Read live, the rollback must be checked against the latest revisions
+	revisions, err := kubeapi.ListDeploymentRevisions(kubeapi.LiveCache(clientSet), namespace, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list revisions")
+	}
+	target := slices.IndexFunc(revisions, func(rev kubeapi.Revision) bool { return rev.Number == revision })
+	if target == -1 {
+		return nil, newActionError(http.StatusNotFound, "Revision not found")
+	}
+	oldImage := ""
+	if current := slices.IndexFunc(revisions, func(rev kubeapi.Revision) bool { return rev.Current }); current != -1 {
+		oldImage = kubeapi.ContainerImages(revisions[current].Template)
+	}
+	newImage := kubeapi.ContainerImages(revisions[target].Template)
+
+	if c.isProtected(namespace) {
+		return c.createChangeRequest(store.ChangeRequest{
+			RequestedBy: userEmail,
+			Cluster:     c.clusterFrom(r).Name,
+			Namespace:   namespace,
+			Kind:        string(kubeapi.KindDeployment),
+			Deployment:  name,
+			Revision:    revision,
+			OldImage:    oldImage,
+			NewImage:    newImage,
+		})
+	}
+
+	err = kubeapi.RollbackDeployment(clientSet, namespace, name, revision)
+	c.recordActivity(store.Activity{
+		User:       userEmail,
+		Action:     store.ActionRollback,
+		Cluster:    c.clusterFrom(r).Name,
+		Namespace:  namespace,
+		Kind:       string(kubeapi.KindDeployment),
+		Deployment: name,
+		Revision:   revision,
+		OldImage:   oldImage,
+		NewImage:   newImage,
+	}, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to rollback deployment")
+	}
+
+	return nil, nil
+}
+
+// createChangeRequest creates a pending change request, instead of applying the change right away.
+func (c *ServerConfig) createChangeRequest(request store.ChangeRequest) (*store.ChangeRequest, error) {
+	now := time.Now()
+	request.CreatedAt = now
+	request.ExpiresAt = now.Add(c.settings().RequestTTL)
+
+	created, err := c.Store.CreateChangeRequest(request)
+	c.recordActivity(store.Activity{
+		User:       request.RequestedBy,
+		Action:     store.ActionRequest,
+		Cluster:    request.Cluster,
+		Namespace:  request.Namespace,
+		Kind:       request.Kind,
+		Deployment: request.Deployment,
+		Container:  request.Container,
+		Revision:   request.Revision,
+		OldImage:   request.OldImage,
+		NewImage:   request.NewImage,
+	}, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create change request")
+	}
+
+	return &created, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+	"github.com/kunalsin9h/upkube/views"
+)
+
+const defaultActivityLimit = 200
+
+// recordActivity saves the outcome of an action in the activity log.
+// Failing to record is logged, it never fails the action itself.
+func (c *ServerConfig) recordActivity(activity store.Activity, err error) {
+	activity.Result = store.ResultSuccess
+	if err != nil {
+		activity.Result = store.ResultFailure
+		activity.Error = err.Error()
+	}
+
+	if err := c.Store.AddActivity(activity); err != nil {
+		log.Errorf("Failed to record activity: %v", err)
+	}
+}
+
+func (c *ServerConfig) ActivityLogs(w http.ResponseWriter, r *http.Request) {
+	userEmail := identityFrom(r).Email
+
+	query := r.URL.Query()
+	filter := store.ActivityFilter{
+		User:       query.Get("user"),
+		Namespace:  query.Get("namespace"),
+		Deployment: query.Get("deployment"),
+		Action:     query.Get("action"),
+		Result:     query.Get("result"),
+		Limit:      defaultActivityLimit,
+		AllowedNamespace: func(namespace string) bool {
+			return c.allowed(r, namespace, policy.VerbView)
+		},
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+
+	activities, err := c.Store.ListActivity(filter)
+	if err != nil {
+		log.Errorf("Failed to list activity: %v", err)
+		http.Error(w, "Failed to list activity: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	root := views.Root(views.ActivityLogs(userEmail, activities, filter))
+	root.Render(r.Context(), w)
+}
@@ -2,32 +2,60 @@ package api
 
 import (
 	"net/http"
-	"strings"
+	"slices"
 
+	"github.com/charmbracelet/log"
 	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
 	"github.com/kunalsin9h/upkube/views"
 )
 
-func (c *ServerConfig) WebHome(w http.ResponseWriter, r *http.Request) {
-	// Extract Cloudflare ZeroTrust custom header passed after auth
-	userEmail := r.Header.Get("Cf-Access-Authenticated-User-Email")
-
-	if userEmail == "" {
-		if strings.EqualFold(c.Env, "PROD") {
-			// In production, we expect the user to be authenticated
-			// Not authenticated or header missing
-			http.Error(w, "Unauthorized: Cloudflare ZeroTrust Authentication is required.", http.StatusUnauthorized)
-			return
+// namespacesFor lists the namespaces the request user may view, along with the selected one.
+// The configured namespaces are used as they are, without listing the namespaces of the cluster.
+func (c *ServerConfig) namespacesFor(r *http.Request, cache *kubeapi.Cache) ([]string, string, error) {
+	namespaces := slices.Clone(c.settings().Namespaces)
+	if len(namespaces) == 0 {
+		var err error
+		if namespaces, err = kubeapi.GetAllNameSpaces(cache); err != nil {
+			return nil, "", err
 		}
-		userEmail = "dev.user@upkube"
 	}
+	// Only show namespaces the user is allowed to view
+	namespaces = c.filterNamespaces(r, namespaces)
 
 	namespace := r.URL.Query().Get("namespace")
 	if namespace == "" {
 		namespace = "default"
+		if !slices.Contains(namespaces, namespace) && len(namespaces) > 0 {
+			namespace = namespaces[0]
+		}
+	}
+
+	return namespaces, namespace, nil
+}
+
+func (c *ServerConfig) WebHome(w http.ResponseWriter, r *http.Request) {
+	identity := identityFrom(r)
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	namespaces, namespace, err := c.namespacesFor(r, cache)
+	if err != nil {
+		log.Errorf("Failed to load namespaces: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return
 	}
 
-	root := views.Root(userEmail, c.ClientSet, namespace)
+	if !c.allowed(r, namespace, policy.VerbView) {
+		forbidden(w, namespace, policy.VerbView)
+		return
+	}
+
+	root := views.Root(views.Dashboard(identity.Email, cache, namespaces, namespace, c.settings().ScaleBounds, c.workloadSelector()))
 	root.Render(r.Context(), w)
 }
 
@@ -38,13 +66,18 @@ func (c *ServerConfig) RestartDeployment(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Missing parameters", http.StatusBadRequest)
 		return
 	}
-	// TODO: Send some notification to the user.
-	err := kubeapi.RestartDeployment(c.ClientSet, namespace, deployment)
+	kind, err := kubeapi.ParseWorkloadKind(r.FormValue("kind"))
 	if err != nil {
-		http.Error(w, "Failed to restart deployment: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+
+	// TODO: Send some notification to the user.
+	if err := c.restartWorkload(r, kind, namespace, deployment); err != nil {
+		writeError(w, err)
+		return
+	}
+	redirectToRollout(w, r, kind, namespace, deployment, "/")
 }
 
 func (c *ServerConfig) UpdateDeploymentImage(w http.ResponseWriter, r *http.Request) {
@@ -55,21 +88,32 @@ func (c *ServerConfig) UpdateDeploymentImage(w http.ResponseWriter, r *http.Requ
 
 	namespace := r.FormValue("namespace")
 	deployment := r.FormValue("deployment")
+	container := r.FormValue("container")
 	imagePrefix := r.FormValue("imagePrefix")
 	oldTag := r.FormValue("oldTag")
 	tag := r.FormValue("tag")
 
-	if namespace == "" || deployment == "" || oldTag == "" || imagePrefix == "" || tag == "" {
+	if namespace == "" || deployment == "" || container == "" || oldTag == "" || imagePrefix == "" || tag == "" {
 		http.Error(w, "Missing parameters", http.StatusBadRequest)
 		return
 	}
+	kind, err := kubeapi.ParseWorkloadKind(r.FormValue("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	oldImage := imagePrefix + ":" + oldTag
 	newImage := imagePrefix + ":" + tag
 
-	err := kubeapi.UpdateDeploymentImage(c.ClientSet, namespace, deployment, newImage)
+	request, err := c.updateWorkloadImage(r, kind, namespace, deployment, container, oldImage, newImage)
 	if err != nil {
-		http.Error(w, "Failed to update image: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	if request != nil {
+		http.Redirect(w, r, "/requests", http.StatusSeeOther)
 		return
 	}
-	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+	redirectToRollout(w, r, kind, namespace, deployment, r.Header.Get("Referer"))
 }
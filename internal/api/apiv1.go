@@ -0,0 +1,364 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+)
+
+// JSON API under /api/v1, for scripts and CI. Actions go through the same functions as the HTML forms,
+// so they are authorized by the same policy and recorded in the same activity log.
+
+const maxAPIBodySize = 1 << 20
+
+const (
+	apiStatusApplied = "applied"
+	// The namespace is protected, the change waits for a second user to approve it
+	apiStatusPending = "pending"
+)
+
+type apiError struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+type apiContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	Init  bool   `json:"init,omitempty"`
+}
+
+type apiWorkload struct {
+	Kind            string         `json:"kind"`
+	Namespace       string         `json:"namespace"`
+	Name            string         `json:"name"`
+	DesiredReplicas int32          `json:"desiredReplicas"`
+	ReadyReplicas   int32          `json:"readyReplicas"`
+	Containers      []apiContainer `json:"containers"`
+	Created         time.Time      `json:"created"`
+	// Rollout is only set when getting a single Deployment
+	Rollout *apiRollout `json:"rollout,omitempty"`
+}
+
+type apiRollout struct {
+	Phase     kubeapi.RolloutPhase `json:"phase"`
+	Message   string               `json:"message"`
+	Updated   int32                `json:"updated"`
+	Available int32                `json:"available"`
+}
+
+type apiActionResult struct {
+	Status  string               `json:"status"`
+	Request *store.ChangeRequest `json:"request,omitempty"`
+}
+
+type apiImageUpdate struct {
+	Container string `json:"container"`
+	Image     string `json:"image"`
+}
+
+type apiScale struct {
+	Replicas *int32 `json:"replicas"`
+}
+
+type apiRollback struct {
+	Revision int64 `json:"revision"`
+}
+
+func newAPIWorkload(workload kubeapi.Workload) apiWorkload {
+	result := apiWorkload{
+		Kind:            string(workload.Kind),
+		Namespace:       workload.Namespace,
+		Name:            workload.Name,
+		DesiredReplicas: workload.DesiredReplicas,
+		ReadyReplicas:   workload.ReadyReplicas,
+		Containers:      []apiContainer{},
+		Created:         workload.CreationTimestamp.Time,
+	}
+	for _, container := range workload.Template.Spec.InitContainers {
+		result.Containers = append(result.Containers, apiContainer{Name: container.Name, Image: container.Image, Init: true})
+	}
+	for _, container := range workload.Template.Spec.Containers {
+		result.Containers = append(result.Containers, apiContainer{Name: container.Name, Image: container.Image})
+	}
+
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeJSONError answers an error as {"status": ..., "error": ...}.
+func writeJSONError(w http.ResponseWriter, err error) {
+	status, message := errorStatus(err)
+	writeJSON(w, status, apiError{Status: status, Error: message})
+}
+
+func writeActionResult(w http.ResponseWriter, request *store.ChangeRequest) {
+	if request != nil {
+		writeJSON(w, http.StatusAccepted, apiActionResult{Status: apiStatusPending, Request: request})
+		return
+	}
+	writeJSON(w, http.StatusOK, apiActionResult{Status: apiStatusApplied})
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, body any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(body); err != nil {
+		return newActionError(http.StatusBadRequest, "Bad Request: invalid JSON body: %v", err)
+	}
+
+	return nil
+}
+
+// apiWorkloadPath reads the namespace, kind and name of the workload in the path.
+// CronJobs are not workloads of the API, they have their own endpoints.
+func apiWorkloadPath(r *http.Request) (string, kubeapi.WorkloadKind, string, error) {
+	kind, err := kubeapi.ParseWorkloadKind(r.PathValue("kind"))
+	if err != nil {
+		return "", "", "", newActionError(http.StatusBadRequest, "Bad Request: %v", err)
+	}
+	if kind == kubeapi.KindCronJob {
+		return "", "", "", newActionError(http.StatusBadRequest, "Bad Request: CronJobs are not supported by the workload endpoints, use POST /cronjobs/run and /cronjobs/suspend instead.")
+	}
+
+	return r.PathValue("namespace"), kind, r.PathValue("name"), nil
+}
+
+// APIListClusters lists the clusters upkube manages, requests name one with the cluster query parameter
+// or the X-Upkube-Cluster header, and act on the first one otherwise. It is empty without a clusters file.
+func (c *ServerConfig) APIListClusters(w http.ResponseWriter, r *http.Request) {
+	names := slices.DeleteFunc(c.clusterNames(), func(name string) bool { return name == "" })
+	writeJSON(w, http.StatusOK, map[string][]string{"clusters": names})
+}
+
+// APIListNamespaces lists the namespaces the user may view.
+func (c *ServerConfig) APIListNamespaces(w http.ResponseWriter, r *http.Request) {
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	namespaces, _, err := c.namespacesFor(r, cache)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if namespaces == nil {
+		namespaces = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]string{"namespaces": namespaces})
+}
+
+func (c *ServerConfig) APIListWorkloads(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	if !c.allowed(r, namespace, policy.VerbView) {
+		writeJSONError(w, errForbidden(namespace, policy.VerbView))
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	workloads, err := kubeapi.ListWorkloads(cache, namespace, c.workloadSelector())
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	result := []apiWorkload{}
+	for _, workload := range workloads {
+		// API tokens may only see some of the workloads
+		if !c.allowedWorkload(r, namespace, workload.Name, policy.VerbView) {
+			continue
+		}
+		result = append(result, newAPIWorkload(workload))
+	}
+	writeJSON(w, http.StatusOK, map[string][]apiWorkload{"workloads": result})
+}
+
+// APIGetWorkload gets a workload, with the status of its rollout when it is a Deployment.
+func (c *ServerConfig) APIGetWorkload(w http.ResponseWriter, r *http.Request) {
+	namespace, kind, name, err := apiWorkloadPath(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if !c.allowedWorkload(r, namespace, name, policy.VerbView) {
+		writeJSONError(w, errForbiddenWorkload(namespace, name, policy.VerbView))
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	workload, err := kubeapi.GetWorkload(cache, kind, namespace, name)
+	if err == nil {
+		err = c.checkManaged(kind, workload.ObjectMeta)
+	}
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	result := newAPIWorkload(*workload)
+	if kind == kubeapi.KindDeployment {
+		status, err := kubeapi.GetRolloutStatus(cache, namespace, name)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		result.Rollout = &apiRollout{
+			Phase:     status.Phase,
+			Message:   status.Message,
+			Updated:   status.Updated,
+			Available: status.Available,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (c *ServerConfig) APIRestartWorkload(w http.ResponseWriter, r *http.Request) {
+	namespace, kind, name, err := apiWorkloadPath(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	if err := c.restartWorkload(r, kind, namespace, name); err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	writeActionResult(w, nil)
+}
+
+// APIUpdateImage sets the image of a container, the image it replaces is read from the workload.
+func (c *ServerConfig) APIUpdateImage(w http.ResponseWriter, r *http.Request) {
+	namespace, kind, name, err := apiWorkloadPath(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	var body apiImageUpdate
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if body.Container == "" || body.Image == "" {
+		writeJSONError(w, newActionError(http.StatusBadRequest, "Bad Request: container and image are required"))
+		return
+	}
+	if !c.allowedWorkload(r, namespace, name, policy.VerbUpdateImage) {
+		writeJSONError(w, errForbiddenWorkload(namespace, name, policy.VerbUpdateImage))
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	workload, err := kubeapi.GetWorkload(cache, kind, namespace, name)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	oldImage, found := "", false
+	for _, container := range slices.Concat(workload.Template.Spec.InitContainers, workload.Template.Spec.Containers) {
+		if container.Name == body.Container {
+			oldImage, found = container.Image, true
+		}
+	}
+	if !found {
+		writeJSONError(w, newActionError(http.StatusNotFound, "container %q not found in pod template", body.Container))
+		return
+	}
+
+	request, err := c.updateWorkloadImage(r, kind, namespace, name, body.Container, oldImage, body.Image)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	writeActionResult(w, request)
+}
+
+func (c *ServerConfig) APIScaleDeployment(w http.ResponseWriter, r *http.Request) {
+	namespace, kind, name, err := apiWorkloadPath(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if kind != kubeapi.KindDeployment {
+		writeJSONError(w, newActionError(http.StatusBadRequest, "Bad Request: only deployments can be scaled"))
+		return
+	}
+
+	var body apiScale
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if body.Replicas == nil {
+		writeJSONError(w, newActionError(http.StatusBadRequest, "Bad Request: replicas is required"))
+		return
+	}
+
+	if err := c.scaleDeployment(r, namespace, name, *body.Replicas); err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	writeActionResult(w, nil)
+}
+
+func (c *ServerConfig) APIRollbackDeployment(w http.ResponseWriter, r *http.Request) {
+	namespace, kind, name, err := apiWorkloadPath(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if kind != kubeapi.KindDeployment {
+		writeJSONError(w, newActionError(http.StatusBadRequest, "Bad Request: only deployments can be rolled back"))
+		return
+	}
+
+	var body apiRollback
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if body.Revision <= 0 {
+		writeJSONError(w, newActionError(http.StatusBadRequest, "Bad Request: revision must be a positive number"))
+		return
+	}
+
+	request, err := c.rollbackDeployment(r, namespace, name, body.Revision)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	writeActionResult(w, request)
+}
+
+// APINotFound answers unknown /api paths with a JSON error, instead of the dashboard.
+func (c *ServerConfig) APINotFound(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, newActionError(http.StatusNotFound, "Not Found: %s %s", r.Method, r.URL.Path))
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalsin9h/upkube/internal/store"
+)
+
+func TestAPIWorkloadEndpointsRejectCronJobs(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "upkube.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer db.Close()
+
+	c := &ServerConfig{Store: db}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		handler http.HandlerFunc
+	}{
+		{name: "get", method: http.MethodGet, path: "", handler: c.APIGetWorkload},
+		{name: "restart", method: http.MethodPost, path: "/restart", handler: c.APIRestartWorkload},
+		{name: "image", method: http.MethodPost, path: "/image", body: `{"container": "backup", "image": "backup:v2"}`, handler: c.APIUpdateImage},
+		{name: "scale", method: http.MethodPost, path: "/scale", body: `{"replicas": 2}`, handler: c.APIScaleDeployment},
+		{name: "rollback", method: http.MethodPost, path: "/rollback", body: `{"revision": 1}`, handler: c.APIRollbackDeployment},
+	}
+
+	for _, tt := range tests {
+		for _, kind := range []string{"cronjob", "CronJob"} {
+			t.Run(tt.name+"/"+kind, func(t *testing.T) {
+				r := httptest.NewRequest(tt.method, "/api/v1/namespaces/default/workloads/"+kind+"/backup"+tt.path, strings.NewReader(tt.body))
+				r.SetPathValue("namespace", "default")
+				r.SetPathValue("kind", kind)
+				r.SetPathValue("name", "backup")
+				w := httptest.NewRecorder()
+
+				tt.handler(w, r)
+
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+				}
+				var body apiError
+				if err := json.NewDecoder(w.Body).Decode(&body); err != nil || !strings.Contains(body.Error, "/cronjobs/run") {
+					t.Errorf("error = %q, want it to point to the CronJob endpoints", body.Error)
+				}
+			})
+		}
+	}
+
+	activities, err := db.ListActivity(store.ActivityFilter{})
+	if err != nil {
+		t.Fatalf("failed to list activity: %v", err)
+	}
+	if len(activities) != 0 {
+		t.Errorf("rejected requests are recorded in the activity log: %+v", activities)
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+const devUserEmail = "dev.user@upkube"
+
+var (
+	errMissingAuth  = errors.New("missing Cloudflare Access credentials")
+	errMissingEmail = errors.New("token does not carry an email claim")
+)
+
+// tokenUserPrefix prefixes the name of an API token, to attribute its calls in the activity log
+// and to impersonate it in the cluster.
+const tokenUserPrefix = "token:"
+
+// Identity is the authenticated user making a request.
+type Identity struct {
+	Email  string
+	Groups []string
+	// Token is set when the request authenticated with an API token, its scope applies instead of the policy
+	Token *store.APIToken
+}
+
+type identityKey struct{}
+
+// identityFrom returns the identity the Authenticate middleware attached to the request.
+func identityFrom(r *http.Request) Identity {
+	identity, _ := r.Context().Value(identityKey{}).(Identity)
+	return identity
+}
+
+// accessClaims are the claims upkube reads from a Cloudflare Access application token.
+// Groups are only present when the identity provider passes them as (custom) OIDC claims.
+type accessClaims struct {
+	Email  string   `json:"email"`
+	Groups []string `json:"groups"`
+	Custom struct {
+		Groups []string `json:"groups"`
+	} `json:"custom"`
+}
+
+// NewAccessVerifier creates a verifier for Cloudflare Access application tokens.
+// Keys are fetched from certsURL and cached, they are only fetched again for an unknown key id.
+// When certsURL is empty, it defaults to the team domain certs endpoint.
+func NewAccessVerifier(teamDomain, audience, certsURL string) *oidc.IDTokenVerifier {
+	teamDomain = strings.TrimSuffix(teamDomain, "/")
+	if !strings.HasPrefix(teamDomain, "https://") && !strings.HasPrefix(teamDomain, "http://") {
+		teamDomain = "https://" + teamDomain
+	}
+	if certsURL == "" {
+		certsURL = teamDomain + "/cdn-cgi/access/certs"
+	}
+
+	keySet := oidc.NewRemoteKeySet(context.Background(), certsURL)
+	return oidc.NewVerifier(teamDomain, keySet, &oidc.Config{ClientID: audience})
+}
+
+// Authenticate attaches the user identity to every request passing through it.
+// With a verifier, the Cloudflare Access token is validated (signature, audience, issuer and expiry),
+// otherwise the email header Cloudflare ZeroTrust passes after auth is trusted.
+// The JSON API also accepts API tokens as a Bearer Authorization header.
+func (c *ServerConfig) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := c.authenticate(r)
+		if err != nil {
+			log.Warnf("Rejected unauthenticated request to %s: %v", r.URL.Path, err)
+			message := "Unauthorized: Cloudflare ZeroTrust Authentication is required."
+			if errors.Is(err, store.ErrTokenInvalid) {
+				message = "Unauthorized: the API token is invalid, revoked or expired."
+			}
+			err := newActionError(http.StatusUnauthorized, "%s", message)
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeJSONError(w, err)
+			} else {
+				writeError(w, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), identityKey{}, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (c *ServerConfig) authenticate(r *http.Request) (Identity, error) {
+	if rawToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.HasPrefix(r.URL.Path, "/api/") {
+		return c.verifyAPIToken(rawToken)
+	}
+
+	if c.AccessVerifier != nil {
+		return c.verifyAccessToken(r)
+	}
+
+	// Extract Cloudflare ZeroTrust custom header passed after auth
+	userEmail := r.Header.Get("Cf-Access-Authenticated-User-Email")
+	if userEmail == "" {
+		if strings.EqualFold(c.Env, "PROD") {
+			// In production, we expect the user to be authenticated
+			return Identity{}, errMissingAuth
+		}
+		userEmail = devUserEmail
+	}
+
+	return Identity{Email: userEmail}, nil
+}
+
+func (c *ServerConfig) verifyAccessToken(r *http.Request) (Identity, error) {
+	rawToken := r.Header.Get("Cf-Access-Jwt-Assertion")
+	if rawToken == "" {
+		// Browsers also carry the token as a cookie
+		if cookie, err := r.Cookie("CF_Authorization"); err == nil {
+			rawToken = cookie.Value
+		}
+	}
+	if rawToken == "" {
+		return Identity{}, errMissingAuth
+	}
+
+	token, err := c.AccessVerifier.Verify(r.Context(), rawToken)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	var claims accessClaims
+	if err := token.Claims(&claims); err != nil {
+		return Identity{}, err
+	}
+	if claims.Email == "" {
+		return Identity{}, errMissingEmail
+	}
+
+	groups := claims.Groups
+	if len(groups) == 0 {
+		groups = claims.Custom.Groups
+	}
+
+	return Identity{Email: claims.Email, Groups: groups}, nil
+}
+
+func (c *ServerConfig) verifyAPIToken(rawToken string) (Identity, error) {
+	token, err := c.Store.VerifyAPIToken(strings.TrimSpace(rawToken))
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return Identity{Email: tokenUserPrefix + token.Name, Token: &token}, nil
+}
+
+// allowed reports whether the request user may perform verb in namespace, according to the policy.
+func (c *ServerConfig) allowed(r *http.Request, namespace string, verb policy.Verb) bool {
+	return c.allowedWorkload(r, namespace, "", verb)
+}
+
+// allowedWorkload is allowed for actions on a single workload, API tokens can also be scoped to workload names.
+func (c *ServerConfig) allowedWorkload(r *http.Request, namespace, name string, verb policy.Verb) bool {
+	if !c.settings().NamespaceFilter.Allows(namespace) {
+		return false
+	}
+
+	identity := identityFrom(r)
+	if identity.Token != nil {
+		return identity.Token.Allows(namespace, name, string(verb))
+	}
+
+	return c.settings().Policy.Allowed(identity.Email, identity.Groups, namespace, verb)
+}
+
+// filterNamespaces returns the namespaces the request user may view.
+func (c *ServerConfig) filterNamespaces(r *http.Request, namespaces []string) []string {
+	filter := c.settings().NamespaceFilter
+	namespaces = slices.DeleteFunc(namespaces, func(namespace string) bool { return !filter.Allows(namespace) })
+
+	identity := identityFrom(r)
+	if identity.Token != nil {
+		return slices.DeleteFunc(namespaces, func(namespace string) bool {
+			return !identity.Token.Allows(namespace, "", string(policy.VerbView))
+		})
+	}
+
+	return c.settings().Policy.FilterNamespaces(identity.Email, identity.Groups, namespaces)
+}
+
+// isAdmin reports whether the request user may manage API tokens, API tokens never do.
+func (c *ServerConfig) isAdmin(r *http.Request) bool {
+	identity := identityFrom(r)
+	return identity.Token == nil && c.settings().Policy.IsAdmin(identity.Email, identity.Groups)
+}
+
+func forbidden(w http.ResponseWriter, namespace string, verb policy.Verb) {
+	writeError(w, errForbidden(namespace, verb))
+}
+
+// clientSetFor returns the clientSet to make Kubernetes calls with on behalf of the request user, in the request cluster.
+func (c *ServerConfig) clientSetFor(r *http.Request) (*kubernetes.Clientset, error) {
+	return c.clientSetIn(r, c.clusterFrom(r))
+}
+
+// clientSetIn returns the clientSet to make Kubernetes calls with on behalf of the request user, in cluster.
+// With impersonation, it acts as the user and their groups, otherwise as the service account.
+func (c *ServerConfig) clientSetIn(r *http.Request, cluster *kubeapi.Cluster) (*kubernetes.Clientset, error) {
+	if !c.Impersonate {
+		return cluster.ClientSet, nil
+	}
+
+	identity := identityFrom(r)
+	clientSet, err := kubeapi.NewImpersonatedClientSet(cluster.RestConfig, identity.Email, identity.Groups)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to impersonate %s", identity.Email)
+	}
+
+	return clientSet, nil
+}
+
+// cacheFor returns the cache pages read from. Impersonated users can not share the informer cache,
+// since the cluster may allow them to see less than the service account.
+func (c *ServerConfig) cacheFor(r *http.Request) (*kubeapi.Cache, error) {
+	cluster := c.clusterFrom(r)
+	if !c.Impersonate && cluster.Cache != nil {
+		return cluster.Cache, nil
+	}
+
+	clientSet, err := c.clientSetIn(r, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubeapi.LiveCache(clientSet), nil
+}
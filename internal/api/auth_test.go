@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	testKeyID    = "test-key"
+	testAudience = "upkube-audience"
+)
+
+// signTestToken signs claims as an RS256 JWT, the way Cloudflare Access signs application tokens.
+func signTestToken(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": testKeyID})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// newTestAccessServer serves the public key of key as the certs endpoint of a Cloudflare Access team domain.
+func newTestAccessServer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+
+	jwks := map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": testKeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func TestVerifyAccessToken(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+	server := newTestAccessServer(t, key)
+
+	c := &ServerConfig{AccessVerifier: NewAccessVerifier(server.URL, testAudience, server.URL+"/cdn-cgi/access/certs")}
+
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   server.URL,
+			"aud":   []string{testAudience},
+			"sub":   "user-id",
+			"email": "alice@corp.com",
+			"iat":   time.Now().Add(-time.Minute).Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name       string
+		signWith   *rsa.PrivateKey
+		claims     map[string]any
+		cookie     bool
+		wantErr    bool
+		wantEmail  string
+		wantGroups []string
+	}{
+		{
+			name:      "valid",
+			signWith:  key,
+			claims:    validClaims(),
+			wantEmail: "alice@corp.com",
+		},
+		{
+			name:      "valid as a cookie",
+			signWith:  key,
+			claims:    validClaims(),
+			cookie:    true,
+			wantEmail: "alice@corp.com",
+		},
+		{
+			name:       "groups",
+			signWith:   key,
+			claims:     with("groups", []string{"sre"}),
+			wantEmail:  "alice@corp.com",
+			wantGroups: []string{"sre"},
+		},
+		{
+			name:       "custom groups",
+			signWith:   key,
+			claims:     with("custom", map[string]any{"groups": []string{"dev"}}),
+			wantEmail:  "alice@corp.com",
+			wantGroups: []string{"dev"},
+		},
+		{
+			name:     "bad signature",
+			signWith: otherKey,
+			claims:   validClaims(),
+			wantErr:  true,
+		},
+		{
+			name:     "wrong audience",
+			signWith: key,
+			claims:   with("aud", []string{"another-app"}),
+			wantErr:  true,
+		},
+		{
+			name:     "wrong issuer",
+			signWith: key,
+			claims:   with("iss", "https://another.cloudflareaccess.com"),
+			wantErr:  true,
+		},
+		{
+			name:     "expired",
+			signWith: key,
+			claims:   with("exp", time.Now().Add(-time.Minute).Unix()),
+			wantErr:  true,
+		},
+		{
+			name:     "missing email",
+			signWith: key,
+			claims:   with("email", nil),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signTestToken(t, tt.signWith, tt.claims)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie {
+				r.AddCookie(&http.Cookie{Name: "CF_Authorization", Value: token})
+			} else {
+				r.Header.Set("Cf-Access-Jwt-Assertion", token)
+			}
+
+			identity, err := c.verifyAccessToken(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("verifyAccessToken() = %+v, want an error", identity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyAccessToken() error = %v", err)
+			}
+			if identity.Email != tt.wantEmail || !slices.Equal(identity.Groups, tt.wantGroups) {
+				t.Errorf("verifyAccessToken() = %q %v, want %q %v", identity.Email, identity.Groups, tt.wantEmail, tt.wantGroups)
+			}
+		})
+	}
+}
+
+func TestVerifyAccessTokenMissing(t *testing.T) {
+	server := newTestAccessServer(t, newTestKey(t))
+	c := &ServerConfig{AccessVerifier: NewAccessVerifier(server.URL, testAudience, server.URL+"/cdn-cgi/access/certs")}
+
+	// The email header alone is not trusted once tokens are verified
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Cf-Access-Authenticated-User-Email", "alice@corp.com")
+
+	if _, err := c.authenticate(r); err != errMissingAuth {
+		t.Fatalf("authenticate() error = %v, want %v", err, errMissingAuth)
+	}
+}
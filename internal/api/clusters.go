@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/views"
+)
+
+// clusterCookie remembers the cluster a user switched to, for the pages and links that do not carry it.
+const clusterCookie = "upkube_cluster"
+
+type clusterKey struct{}
+
+// clusterFrom returns the cluster the SelectCluster middleware attached to the request.
+func (c *ServerConfig) clusterFrom(r *http.Request) *kubeapi.Cluster {
+	if cluster, ok := r.Context().Value(clusterKey{}).(*kubeapi.Cluster); ok {
+		return cluster
+	}
+
+	return c.Clusters[0]
+}
+
+// withCluster makes r act on cluster.
+func withCluster(r *http.Request, cluster *kubeapi.Cluster) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clusterKey{}, cluster))
+}
+
+// clusterNamed finds a cluster by name, an empty name is the default cluster.
+func (c *ServerConfig) clusterNamed(name string) (*kubeapi.Cluster, bool) {
+	if name == "" {
+		return c.Clusters[0], true
+	}
+
+	idx := slices.IndexFunc(c.Clusters, func(cluster *kubeapi.Cluster) bool { return cluster.Name == name })
+	if idx == -1 {
+		return nil, false
+	}
+
+	return c.Clusters[idx], true
+}
+
+// SelectCluster attaches the cluster a request acts on. It is named by the cluster query parameter,
+// the cluster field of forms, the X-Upkube-Cluster header for the API, or else the cookie set by the selector.
+func (c *ServerConfig) SelectCluster(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isAPI := strings.HasPrefix(r.URL.Path, "/api/")
+
+		name := r.URL.Query().Get("cluster")
+		if name == "" && r.Method == http.MethodPost && !isAPI {
+			name = r.PostFormValue("cluster")
+		}
+		if name == "" {
+			name = r.Header.Get("X-Upkube-Cluster")
+		}
+		// Switching clusters with the selector is a GET with the cluster query parameter
+		remember := name != "" && r.Method == http.MethodGet && !isAPI
+		if cookie, err := r.Cookie(clusterCookie); name == "" && err == nil {
+			// A cluster removed from the clusters file falls back to the default one
+			if _, ok := c.clusterNamed(cookie.Value); ok {
+				name = cookie.Value
+			}
+		}
+
+		cluster, ok := c.clusterNamed(name)
+		if !ok {
+			err := newActionError(http.StatusNotFound, "Not Found: unknown cluster %q", name)
+			if isAPI {
+				writeJSONError(w, err)
+			} else {
+				writeError(w, err)
+			}
+			return
+		}
+		if remember {
+			http.SetCookie(w, &http.Cookie{Name: clusterCookie, Value: name, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		}
+
+		r = withCluster(r, cluster)
+		r = r.WithContext(views.WithClusters(r.Context(), cluster.Name, c.clusterNames()))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *ServerConfig) clusterNames() []string {
+	names := make([]string, 0, len(c.Clusters))
+	for _, cluster := range c.Clusters {
+		names = append(names, cluster.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+	"github.com/kunalsin9h/upkube/views"
+)
+
+func (c *ServerConfig) CronJobs(w http.ResponseWriter, r *http.Request) {
+	identity := identityFrom(r)
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	namespaces, namespace, err := c.namespacesFor(r, cache)
+	if err != nil {
+		log.Errorf("Failed to load namespaces: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return
+	}
+
+	if !c.allowed(r, namespace, policy.VerbView) {
+		forbidden(w, namespace, policy.VerbView)
+		return
+	}
+
+	root := views.Root(views.CronJobs(identity.Email, cache.ClientSet(), namespaces, namespace, c.workloadSelector()))
+	root.Render(r.Context(), w)
+}
+
+// RunCronJob triggers a CronJob manually, by creating a Job from its jobTemplate.
+func (c *ServerConfig) RunCronJob(w http.ResponseWriter, r *http.Request) {
+	namespace := r.FormValue("namespace")
+	cronJob := r.FormValue("cronjob")
+	if namespace == "" || cronJob == "" {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+	if !c.allowed(r, namespace, policy.VerbRestart) {
+		forbidden(w, namespace, policy.VerbRestart)
+		return
+	}
+	userEmail := identityFrom(r).Email
+
+	clientSet, err := c.clientSetFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := c.checkWorkloadAction(clientSet, kubeapi.KindCronJob, namespace, cronJob, kubeapi.AllowRestartAnnotation); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	_, err = kubeapi.RunCronJob(clientSet, namespace, cronJob)
+	c.recordActivity(store.Activity{
+		User:       userEmail,
+		Action:     store.ActionRun,
+		Cluster:    c.clusterFrom(r).Name,
+		Namespace:  namespace,
+		Kind:       string(kubeapi.KindCronJob),
+		Deployment: cronJob,
+	}, err)
+	if err != nil {
+		http.Error(w, "Failed to run cronjob: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
+
+func (c *ServerConfig) SuspendCronJob(w http.ResponseWriter, r *http.Request) {
+	namespace := r.FormValue("namespace")
+	cronJob := r.FormValue("cronjob")
+	suspend, err := strconv.ParseBool(r.FormValue("suspend"))
+	if namespace == "" || cronJob == "" || err != nil {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+	if !c.allowed(r, namespace, policy.VerbRestart) {
+		forbidden(w, namespace, policy.VerbRestart)
+		return
+	}
+	userEmail := identityFrom(r).Email
+
+	clientSet, err := c.clientSetFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := c.checkWorkloadAction(clientSet, kubeapi.KindCronJob, namespace, cronJob, kubeapi.AllowRestartAnnotation); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	action := store.ActionResume
+	if suspend {
+		action = store.ActionSuspend
+	}
+
+	err = kubeapi.SuspendCronJob(clientSet, namespace, cronJob, suspend)
+	c.recordActivity(store.Activity{
+		User:       userEmail,
+		Action:     action,
+		Cluster:    c.clusterFrom(r).Name,
+		Namespace:  namespace,
+		Kind:       string(kubeapi.KindCronJob),
+		Deployment: cronJob,
+	}, err)
+	if err != nil {
+		http.Error(w, "Failed to "+action+" cronjob: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
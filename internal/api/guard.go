@@ -0,0 +1,128 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/store"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const guardPollInterval = 5 * time.Second
+
+// updateImage updates the image of a workload container. The rollout of a guarded Deployment is then
+// watched for GuardWindow, and its previous pod template restored when the new pods fail.
+func (c *ServerConfig) updateImage(cluster *kubeapi.Cluster, clientSet *kubernetes.Clientset, kind kubeapi.WorkloadKind, namespace, name, container, oldImage, newImage, userEmail string) error {
+	if kind != kubeapi.KindDeployment {
+		return kubeapi.UpdateWorkloadImage(clientSet, kind, namespace, name, container, newImage)
+	}
+
+	live := kubeapi.LiveCache(clientSet)
+	previous, err := live.GetDeployment(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	if err := kubeapi.UpdateWorkloadImage(clientSet, kind, namespace, name, container, newImage); err != nil {
+		return err
+	}
+	if !c.isGuarded(namespace, previous.Annotations) {
+		return nil
+	}
+
+	updated, err := live.GetDeployment(namespace, name)
+	if err != nil {
+		log.Errorf("Guarded update of deployment %s/%s is not watched: %v", namespace, name, err)
+		return nil
+	}
+
+	go c.guardRollout(cluster, clientSet, store.Activity{
+		User:       userEmail,
+		Action:     store.ActionAutoRollback,
+		Cluster:    cluster.Name,
+		Namespace:  namespace,
+		Kind:       string(kind),
+		Deployment: name,
+		Container:  container,
+		OldImage:   newImage,
+		NewImage:   oldImage,
+	}, updated.Generation, previous.Spec.Template)
+
+	return nil
+}
+
+// isGuarded reports whether image updates of a Deployment are guarded, the annotation overrides the namespace.
+func (c *ServerConfig) isGuarded(namespace string, annotations map[string]string) bool {
+	if value, ok := annotations[kubeapi.GuardedUpdateAnnotation]; ok {
+		return strings.EqualFold(value, "true")
+	}
+
+	return slices.Contains(c.settings().GuardedNamespaces, namespace)
+}
+
+// guardRollout follows the rollout of generation until it completes, fails, or the window ends.
+// A failed rollout gets the previous pod template back, and the auto-rollback is recorded with its reason.
+func (c *ServerConfig) guardRollout(cluster *kubeapi.Cluster, clientSet *kubernetes.Clientset, activity store.Activity, generation int64, previous corev1.PodTemplateSpec) {
+	cache := cluster.Cache
+	if cache == nil || c.Impersonate {
+		cache = kubeapi.LiveCache(clientSet)
+	}
+
+	window := c.settings().GuardWindow
+	ticker := time.NewTicker(guardPollInterval)
+	defer ticker.Stop()
+	deadline := time.After(window)
+
+	for {
+		last := false
+		select {
+		case <-deadline:
+			// Checked once more, the window may end before the first tick
+			last = true
+		case <-ticker.C:
+		}
+
+		status, err := kubeapi.GetRolloutStatus(cache, activity.Namespace, activity.Deployment)
+		switch {
+		case err != nil:
+			log.Warnf("Failed to get rollout status of guarded deployment %s/%s: %v", activity.Namespace, activity.Deployment, err)
+		case status.Generation < generation:
+			// The cache has not seen the update yet
+		case status.Generation > generation:
+			log.Infof("Deployment %s/%s changed again during its guarded update, no longer watched", activity.Namespace, activity.Deployment)
+			return
+		case status.Phase == kubeapi.RolloutComplete:
+			return
+		case status.Phase == kubeapi.RolloutFailed:
+			activity.Reason = rolloutFailureReason(status)
+			log.Warnf("Guarded update of deployment %s/%s failed, restoring the previous pod template: %s", activity.Namespace, activity.Deployment, activity.Reason)
+
+			err := kubeapi.RestorePodTemplate(clientSet, kubeapi.KindDeployment, activity.Namespace, activity.Deployment, previous)
+			if err != nil {
+				log.Errorf("Failed to roll back deployment %s/%s: %v", activity.Namespace, activity.Deployment, err)
+			}
+			c.recordActivity(activity, err)
+			return
+		}
+
+		if last {
+			log.Infof("Guarded update of deployment %s/%s still rolling out after %s, no longer watched", activity.Namespace, activity.Deployment, window)
+			return
+		}
+	}
+}
+
+func rolloutFailureReason(status *kubeapi.RolloutStatus) string {
+	reason := status.Message
+	for _, pod := range slices.Sorted(maps.Keys(status.FailingPods)) {
+		reason += fmt.Sprintf("\n%s %s", pod, status.FailingPods[pod])
+	}
+
+	return reason
+}
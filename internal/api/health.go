@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type healthStatus struct {
+	Status string `json:"status"`
+	// Whether each informer of the cache has synced, pages read from the apiserver until it has
+	Cache map[string]bool `json:"cache,omitempty"`
+	// The cache state of each cluster, when there are several
+	Clusters map[string]map[string]bool `json:"clusters,omitempty"`
+}
+
+// Health always answers 200, an informer that can not sync (e.g. missing RBAC) only makes pages slower.
+func (c *ServerConfig) Health(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "OK"}
+	if len(c.Clusters) == 1 && c.Clusters[0].Cache != nil {
+		status.Cache = c.Clusters[0].Cache.SyncState()
+	} else if len(c.Clusters) > 1 {
+		status.Clusters = map[string]map[string]bool{}
+		for _, cluster := range c.Clusters {
+			if cluster.Cache != nil {
+				status.Clusters[cluster.Name] = cluster.Cache.SyncState()
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(status)
+}
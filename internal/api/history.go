@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/views"
+)
+
+func (c *ServerConfig) RevisionHistory(w http.ResponseWriter, r *http.Request) {
+	identity := identityFrom(r)
+	namespace := r.PathValue("namespace")
+	deployment := r.PathValue("name")
+
+	if !c.allowed(r, namespace, policy.VerbView) {
+		forbidden(w, namespace, policy.VerbView)
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.checkManagedIn(cache, kubeapi.KindDeployment, namespace, deployment); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	revisions, err := kubeapi.ListDeploymentRevisions(cache, namespace, deployment)
+	if err != nil {
+		log.Errorf("Failed to list revisions: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return
+	}
+
+	root := views.Root(views.RevisionHistory(identity.Email, namespace, deployment, revisions, c.isProtected(namespace)))
+	root.Render(r.Context(), w)
+}
+
+// RollbackDeployment restores the pod template of a previous revision.
+func (c *ServerConfig) RollbackDeployment(w http.ResponseWriter, r *http.Request) {
+	namespace := r.FormValue("namespace")
+	deployment := r.FormValue("deployment")
+	revision, err := strconv.ParseInt(r.FormValue("revision"), 10, 64)
+	if namespace == "" || deployment == "" || err != nil || revision <= 0 {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	request, err := c.rollbackDeployment(r, namespace, deployment, revision)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if request != nil {
+		http.Redirect(w, r, "/requests", http.StatusSeeOther)
+		return
+	}
+	redirectToRollout(w, r, kubeapi.KindDeployment, namespace, deployment, r.Header.Get("Referer"))
+}
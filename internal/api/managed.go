@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workloadSelector selects the workloads upkube shows and acts on, the ones labeled managed when ManagedOnly is set.
+func (c *ServerConfig) workloadSelector() labels.Selector {
+	return kubeapi.ManagedSelector(c.settings().ManagedOnly)
+}
+
+// checkManaged answers workloads upkube does not manage as not found, the same as they are left out of lists.
+func (c *ServerConfig) checkManaged(kind kubeapi.WorkloadKind, meta metav1.ObjectMeta) error {
+	if !c.workloadSelector().Matches(labels.Set(meta.Labels)) {
+		return newActionError(http.StatusNotFound, "Not Found: %s %s is not managed by upkube, it is missing the %s=true label.", kind, meta.Name, kubeapi.ManagedLabel)
+	}
+
+	return nil
+}
+
+// checkManagedIn reads a workload from cache to check upkube manages it, for pages that do not read it otherwise.
+func (c *ServerConfig) checkManagedIn(cache *kubeapi.Cache, kind kubeapi.WorkloadKind, namespace, name string) error {
+	if !c.settings().ManagedOnly {
+		return nil
+	}
+
+	meta, err := kubeapi.GetWorkloadMeta(cache, kind, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	return c.checkManaged(kind, *meta)
+}
+
+// checkWorkloadAction reads a workload live before an action on it, and rejects the action when upkube does not
+// manage the workload or its annotation disables the action. An empty annotation only checks the workload is managed.
+func (c *ServerConfig) checkWorkloadAction(clientSet *kubernetes.Clientset, kind kubeapi.WorkloadKind, namespace, name, annotation string) error {
+	meta, err := kubeapi.GetWorkloadMeta(kubeapi.LiveCache(clientSet), kind, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	return c.checkAction(kind, *meta, annotation)
+}
+
+func (c *ServerConfig) checkAction(kind kubeapi.WorkloadKind, meta metav1.ObjectMeta, annotation string) error {
+	if err := c.checkManaged(kind, meta); err != nil {
+		return err
+	}
+	if annotation != "" && !kubeapi.ActionAllowed(meta.Annotations, annotation) {
+		return newActionError(http.StatusForbidden, "Forbidden: %s %s disables this action with the %s=false annotation.", kind, meta.Name, annotation)
+	}
+
+	return nil
+}
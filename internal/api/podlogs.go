@@ -0,0 +1,190 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/views"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const defaultTailLines = 500
+
+// podLogs is the workload, its pods, and the pod and log a logs page or stream asks for.
+type podLogs struct {
+	cache    *kubeapi.Cache
+	workload *kubeapi.Workload
+	pods     []corev1.Pod
+	pod      string
+	options  kubeapi.LogOptions
+}
+
+// PodLogs lists the pods of a workload and shows the log of one of them, the first pod and container by default.
+func (c *ServerConfig) PodLogs(w http.ResponseWriter, r *http.Request) {
+	logs, ok := c.resolvePodLogs(w, r)
+	if !ok {
+		return
+	}
+
+	root := views.Root(views.PodLogs(identityFrom(r).Email, *logs.workload, logs.pods, logs.pod, logs.options))
+	root.Render(r.Context(), w)
+}
+
+// StreamPodLogs writes a pod log as plain text, flushing as lines come when it is followed.
+// With download=true the log is sent as a file, and never followed.
+func (c *ServerConfig) StreamPodLogs(w http.ResponseWriter, r *http.Request) {
+	logs, ok := c.resolvePodLogs(w, r)
+	if !ok {
+		return
+	}
+	if logs.pod == "" {
+		http.Error(w, "Workload has no pods", http.StatusNotFound)
+		return
+	}
+
+	download := r.URL.Query().Get("download") == "true"
+	if download {
+		logs.options.Follow = false
+	}
+
+	stream, err := kubeapi.StreamPodLogs(r.Context(), logs.cache.ClientSet(), logs.workload.Namespace, logs.pod, logs.options)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if apierrors.IsBadRequest(errors.Cause(err)) {
+			// e.g. there is no previous container, or it is still creating
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if download {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+logs.pod+"-"+logs.options.Container+`.log"`)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if err := flusher.Flush(); err != nil {
+				return
+			}
+		}
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			if r.Context().Err() == nil {
+				log.Warnf("Log stream of pod %s closed: %v", logs.pod, err)
+			}
+			return
+		}
+	}
+}
+
+// resolvePodLogs reads the workload and its pods, and the pod, container and log options of the query.
+// It writes the error response itself when it fails.
+func (c *ServerConfig) resolvePodLogs(w http.ResponseWriter, r *http.Request) (*podLogs, bool) {
+	namespace := r.PathValue("namespace")
+	name := r.PathValue("name")
+	query := r.URL.Query()
+
+	kind, err := kubeapi.ParseWorkloadKind(query.Get("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if !c.allowed(r, namespace, policy.VerbView) {
+		forbidden(w, namespace, policy.VerbView)
+		return nil, false
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	workload, err := kubeapi.GetWorkload(cache, kind, namespace, name)
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		log.Errorf("Failed to get workload: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return nil, false
+	}
+	if err := c.checkManaged(kind, workload.ObjectMeta); err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+
+	pods, err := kubeapi.ListWorkloadPods(cache, namespace, workload.Selector)
+	if err != nil {
+		log.Errorf("Failed to list pods: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return nil, false
+	}
+
+	logs := &podLogs{
+		cache:    cache,
+		workload: workload,
+		pods:     pods,
+		pod:      query.Get("pod"),
+		options: kubeapi.LogOptions{
+			Container: query.Get("container"),
+			TailLines: defaultTailLines,
+			Previous:  query.Get("previous") == "true",
+			Follow:    query.Get("follow") == "true",
+		},
+	}
+	if tailLines := query.Get("tailLines"); tailLines != "" {
+		logs.options.TailLines, err = strconv.ParseInt(tailLines, 10, 64)
+		if err != nil || logs.options.TailLines < 1 {
+			http.Error(w, "Bad Request: tailLines must be a positive number", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	if len(pods) == 0 {
+		logs.pod = ""
+		return logs, true
+	}
+
+	// Only pods of the workload can be read through its logs page
+	index := 0
+	if logs.pod != "" {
+		index = slices.IndexFunc(pods, func(pod corev1.Pod) bool { return pod.Name == logs.pod })
+		if index == -1 {
+			http.Error(w, "Pod "+logs.pod+" is not a pod of "+string(kind)+" "+name, http.StatusNotFound)
+			return nil, false
+		}
+	}
+	pod := pods[index]
+	logs.pod = pod.Name
+
+	containers := slices.Concat(pod.Spec.Containers, pod.Spec.InitContainers)
+	if logs.options.Container == "" && len(containers) > 0 {
+		logs.options.Container = containers[0].Name
+	} else if !slices.ContainsFunc(containers, func(container corev1.Container) bool { return container.Name == logs.options.Container }) {
+		http.Error(w, "Container "+logs.options.Container+" not found in pod "+pod.Name, http.StatusNotFound)
+		return nil, false
+	}
+
+	return logs, true
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DeletePod recycles a single pod of a workload, without restarting the others.
+// It is a restart of one replica, so it needs the restart verb.
+func (c *ServerConfig) DeletePod(w http.ResponseWriter, r *http.Request) {
+	namespace := r.FormValue("namespace")
+	name := r.FormValue("deployment")
+	pod := r.FormValue("pod")
+	if namespace == "" || name == "" || pod == "" {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+	kind, err := kubeapi.ParseWorkloadKind(r.FormValue("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !c.allowed(r, namespace, policy.VerbRestart) {
+		forbidden(w, namespace, policy.VerbRestart)
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Only pods of the workload can be deleted, so the form can not be used on any pod of the namespace
+	workload, err := kubeapi.GetWorkload(cache, kind, namespace, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := c.checkAction(kind, workload.ObjectMeta, kubeapi.AllowRestartAnnotation); err != nil {
+		writeError(w, err)
+		return
+	}
+	pods, err := kubeapi.ListWorkloadPods(cache, namespace, workload.Selector)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !slices.ContainsFunc(pods, func(p corev1.Pod) bool { return p.Name == pod }) {
+		http.Error(w, "Pod "+pod+" is not a pod of "+string(kind)+" "+name, http.StatusNotFound)
+		return
+	}
+
+	clientSet, err := c.clientSetFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = kubeapi.DeletePod(clientSet, namespace, pod)
+	c.recordActivity(store.Activity{
+		User:       identityFrom(r).Email,
+		Action:     store.ActionDeletePod,
+		Cluster:    c.clusterFrom(r).Name,
+		Namespace:  namespace,
+		Kind:       string(kind),
+		Deployment: name,
+		Pod:        pod,
+	}, err)
+	if err != nil {
+		http.Error(w, "Failed to delete pod: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// registryWebhookUser attributes image updates made for registry pushes in the activity log.
+const registryWebhookUser = "registry-webhook"
+
+// registryPush is a tag pushed to a repository, e.g. "ghcr.io/acme/web" and "v1.4.2".
+type registryPush struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+}
+
+type registryUpdate struct {
+	Cluster    string `json:"cluster,omitempty"`
+	Namespace  string `json:"namespace"`
+	Deployment string `json:"deployment"`
+	Container  string `json:"container"`
+	OldImage   string `json:"oldImage"`
+	NewImage   string `json:"newImage"`
+	// Status is applied or pending like for API actions, or failed along with Error
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// registryPayload holds the fields upkube reads from Docker Registry v2 notifications (Events),
+// Harbor webhooks (Type and EventData) and GitHub package events for GHCR (Action and the package).
+type registryPayload struct {
+	Events []struct {
+		Action string `json:"action"`
+		Target struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+		} `json:"target"`
+		Request struct {
+			Host string `json:"host"`
+		} `json:"request"`
+	} `json:"events"`
+
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Tag         string `json:"tag"`
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+
+	Action          string         `json:"action"`
+	Package         *githubPackage `json:"package"`
+	RegistryPackage *githubPackage `json:"registry_package"`
+}
+
+type githubPackage struct {
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	PackageType    string `json:"package_type"`
+	Ecosystem      string `json:"ecosystem"`
+	PackageVersion struct {
+		PackageURL        string `json:"package_url"`
+		ContainerMetadata struct {
+			Tag struct {
+				Name string `json:"name"`
+			} `json:"tag"`
+		} `json:"container_metadata"`
+	} `json:"package_version"`
+}
+
+// pushes returns the tags pushed according to the payload, untagged pushes (by digest) are left out.
+func (p registryPayload) pushes() []registryPush {
+	var pushes []registryPush
+
+	for _, event := range p.Events {
+		if event.Action != "push" || event.Target.Tag == "" || event.Request.Host == "" {
+			continue
+		}
+		pushes = append(pushes, registryPush{Repository: event.Request.Host + "/" + event.Target.Repository, Tag: event.Target.Tag})
+	}
+
+	if p.Type == "PUSH_ARTIFACT" {
+		for _, resource := range p.EventData.Resources {
+			repository, tag := kubeapi.SplitImage(resource.ResourceURL)
+			if resource.Tag == "" || tag != resource.Tag {
+				continue
+			}
+			pushes = append(pushes, registryPush{Repository: repository, Tag: tag})
+		}
+	}
+
+	for _, pkg := range []*githubPackage{p.Package, p.RegistryPackage} {
+		if pkg == nil || p.Action != "published" || !(strings.EqualFold(pkg.PackageType, "container") || strings.EqualFold(pkg.Ecosystem, "container")) {
+			continue
+		}
+		tag := pkg.PackageVersion.ContainerMetadata.Tag.Name
+		if tag == "" {
+			continue
+		}
+		repository := "ghcr.io/" + strings.ToLower(pkg.Namespace) + "/" + strings.ToLower(pkg.Name)
+		if pkg.PackageVersion.PackageURL != "" {
+			repository, _ = kubeapi.SplitImage(pkg.PackageVersion.PackageURL)
+			repository = strings.ToLower(repository)
+		}
+		pushes = append(pushes, registryPush{Repository: repository, Tag: tag})
+	}
+
+	return pushes
+}
+
+// verifyRegistrySignature checks the hex HMAC-SHA256 of the body, which GitHub sends in X-Hub-Signature-256
+// and other senders can send in X-Upkube-Signature-256. Docker registries and Harbor can only send a static
+// header, so with plainSecret they send the secret itself in the Authorization header instead.
+func verifyRegistrySignature(r *http.Request, body []byte, secret string, plainSecret bool) bool {
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = r.Header.Get("X-Upkube-Signature-256")
+	}
+
+	if signature != "" {
+		expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(mac.Sum(nil), expected)
+	}
+
+	if !plainSecret {
+		return false
+	}
+
+	authorization := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return authorization != "" && subtle.ConstantTimeCompare([]byte(authorization), []byte(secret)) == 1
+}
+
+// RegistryWebhook rolls pushed tags out to the Deployments opted in with a tag pattern annotation, in every cluster.
+// Containers whose image prefix is the pushed repository get the new tag, through the same image update
+// as the dashboard, so protected namespaces still create a change request and guarded updates still apply.
+func (c *ServerConfig) RegistryWebhook(w http.ResponseWriter, r *http.Request) {
+	// Without a secret the webhook is disabled
+	settings := c.settings()
+	secret := settings.RegistryWebhookSecret
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIBodySize))
+	if err != nil {
+		writeJSONError(w, newActionError(http.StatusBadRequest, "Bad Request: %v", err))
+		return
+	}
+	if !verifyRegistrySignature(r, body, secret, settings.RegistryWebhookPlainSecret) {
+		log.Warnf("Rejected registry webhook with an invalid signature from %s", r.RemoteAddr)
+		writeJSONError(w, newActionError(http.StatusUnauthorized, "Unauthorized: invalid webhook signature"))
+		return
+	}
+
+	var payload registryPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeJSONError(w, newActionError(http.StatusBadRequest, "Bad Request: invalid JSON body: %v", err))
+		return
+	}
+	pushes := payload.pushes()
+	if pushes == nil {
+		pushes = []registryPush{}
+	}
+
+	updates := c.rolloutPushes(pushes)
+	writeJSON(w, http.StatusOK, map[string]any{"pushes": pushes, "updates": updates})
+}
+
+// rolloutPushes updates the matching containers in every cluster, a cluster whose Deployments can not be listed is skipped.
+func (c *ServerConfig) rolloutPushes(pushes []registryPush) []registryUpdate {
+	updates := []registryUpdate{}
+	if len(pushes) == 0 {
+		return updates
+	}
+
+	for _, cluster := range c.Clusters {
+		cache := cluster.Cache
+		if cache == nil {
+			cache = kubeapi.LiveCache(cluster.ClientSet)
+		}
+		deployments, err := cache.ListDeployments("", c.workloadSelector())
+		if err != nil {
+			log.Errorf("Failed to list deployments for registry pushes in cluster %q: %v", cluster.Name, err)
+			continue
+		}
+
+		for _, push := range pushes {
+			updates = append(updates, c.rolloutPush(cluster, deployments, push)...)
+		}
+	}
+
+	return updates
+}
+
+func (c *ServerConfig) rolloutPush(cluster *kubeapi.Cluster, deployments []appsv1.Deployment, push registryPush) []registryUpdate {
+	var updates []registryUpdate
+
+	for _, deployment := range deployments {
+		if !kubeapi.MatchesTagPattern(deployment, push.Tag) || !c.settings().NamespaceFilter.Allows(deployment.Namespace) {
+			continue
+		}
+
+		spec := deployment.Spec.Template.Spec
+		for _, container := range slices.Concat(spec.InitContainers, spec.Containers) {
+			repository, tag := kubeapi.SplitImage(container.Image)
+			if repository != push.Repository || tag == push.Tag {
+				continue
+			}
+
+			update := registryUpdate{
+				Cluster:    cluster.Name,
+				Namespace:  deployment.Namespace,
+				Deployment: deployment.Name,
+				Container:  container.Name,
+				OldImage:   container.Image,
+				NewImage:   repository + ":" + push.Tag,
+				Status:     apiStatusApplied,
+			}
+			request, err := c.applyImageUpdate(cluster, cluster.ClientSet, kubeapi.KindDeployment, update.Namespace, update.Deployment, update.Container, update.OldImage, update.NewImage, registryWebhookUser)
+			if err != nil {
+				log.Errorf("Failed to roll out %s to %s/%s: %v", update.NewImage, update.Namespace, update.Deployment, err)
+				update.Status, update.Error = "failed", err.Error()
+			} else if request != nil {
+				update.Status = apiStatusPending
+			}
+			updates = append(updates, update)
+		}
+	}
+
+	return updates
+}
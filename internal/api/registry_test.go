@@ -0,0 +1,202 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+var (
+	dockerPayload = `{"events": [{
+		"action": "push",
+		"target": {"repository": "acme/web", "tag": "v1.4.2"},
+		"request": {"host": "registry.example.com"}
+	}]}`
+
+	harborPayload = `{
+		"type": "PUSH_ARTIFACT",
+		"event_data": {"resources": [{"tag": "v1.4.2", "resource_url": "harbor.example.com/acme/web:v1.4.2"}]}
+	}`
+
+	// GitHub sends both the package and the registry_package event for a GHCR push
+	githubPackagePayload = `{
+		"action": "published",
+		"package": {
+			"name": "Web",
+			"namespace": "Acme",
+			"package_type": "CONTAINER",
+			"package_version": {"container_metadata": {"tag": {"name": "v1.4.2"}}}
+		}
+	}`
+
+	githubRegistryPackagePayload = `{
+		"action": "published",
+		"registry_package": {
+			"name": "web",
+			"namespace": "Acme",
+			"package_type": "container",
+			"ecosystem": "CONTAINER",
+			"package_version": {
+				"package_url": "ghcr.io/Acme/Web:v1.4.2",
+				"container_metadata": {"tag": {"name": "v1.4.2"}}
+			}
+		}
+	}`
+)
+
+func signPayload(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestRegistryPayloadPushes(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []registryPush
+	}{
+		{name: "docker", payload: dockerPayload, want: []registryPush{{Repository: "registry.example.com/acme/web", Tag: "v1.4.2"}}},
+		{name: "harbor", payload: harborPayload, want: []registryPush{{Repository: "harbor.example.com/acme/web", Tag: "v1.4.2"}}},
+		{name: "github package", payload: githubPackagePayload, want: []registryPush{{Repository: "ghcr.io/acme/web", Tag: "v1.4.2"}}},
+		{name: "github registry package", payload: githubRegistryPackagePayload, want: []registryPush{{Repository: "ghcr.io/acme/web", Tag: "v1.4.2"}}},
+		{name: "docker pull", payload: strings.Replace(dockerPayload, `"push"`, `"pull"`, 1), want: nil},
+		{name: "github npm package", payload: strings.Replace(githubPackagePayload, `"CONTAINER"`, `"npm"`, 1), want: nil},
+		{name: "github package updated", payload: strings.Replace(githubPackagePayload, `"published"`, `"updated"`, 1), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload registryPayload
+			if err := json.Unmarshal([]byte(tt.payload), &payload); err != nil {
+				t.Fatalf("invalid payload: %v", err)
+			}
+
+			if got := payload.pushes(); !slices.Equal(got, tt.want) {
+				t.Errorf("pushes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrySignature(t *testing.T) {
+	payloads := map[string]string{
+		"docker":                  dockerPayload,
+		"harbor":                  harborPayload,
+		"github package":          githubPackagePayload,
+		"github registry package": githubRegistryPackagePayload,
+	}
+
+	tests := []struct {
+		name string
+		// headers builds the request headers for the body
+		headers     func(body string) map[string]string
+		plainSecret bool
+		want        bool
+	}{
+		{
+			name: "github signature",
+			headers: func(body string) map[string]string {
+				return map[string]string{"X-Hub-Signature-256": signPayload(testWebhookSecret, body)}
+			},
+			want: true,
+		},
+		{
+			name: "upkube signature",
+			headers: func(body string) map[string]string {
+				return map[string]string{"X-Upkube-Signature-256": signPayload(testWebhookSecret, body)}
+			},
+			want: true,
+		},
+		{
+			name: "signature without prefix",
+			headers: func(body string) map[string]string {
+				return map[string]string{"X-Upkube-Signature-256": strings.TrimPrefix(signPayload(testWebhookSecret, body), "sha256=")}
+			},
+			want: true,
+		},
+		{
+			name: "signature with another secret",
+			headers: func(body string) map[string]string {
+				return map[string]string{"X-Hub-Signature-256": signPayload("another-secret", body)}
+			},
+			want: false,
+		},
+		{
+			name: "signature of another body",
+			headers: func(body string) map[string]string {
+				return map[string]string{"X-Hub-Signature-256": signPayload(testWebhookSecret, body+" ")}
+			},
+			want: false,
+		},
+		{
+			name:    "signature not hex",
+			headers: func(string) map[string]string { return map[string]string{"X-Hub-Signature-256": "sha256=not-hex"} },
+			want:    false,
+		},
+		{
+			name: "plain secret when disabled",
+			headers: func(string) map[string]string {
+				return map[string]string{"Authorization": "Bearer " + testWebhookSecret}
+			},
+			want: false,
+		},
+		{
+			name: "plain secret",
+			headers: func(string) map[string]string {
+				return map[string]string{"Authorization": "Bearer " + testWebhookSecret}
+			},
+			plainSecret: true,
+			want:        true,
+		},
+		{
+			name:        "plain secret without bearer",
+			headers:     func(string) map[string]string { return map[string]string{"Authorization": testWebhookSecret} },
+			plainSecret: true,
+			want:        true,
+		},
+		{
+			name:        "wrong plain secret",
+			headers:     func(string) map[string]string { return map[string]string{"Authorization": "Bearer another-secret"} },
+			plainSecret: true,
+			want:        false,
+		},
+		{
+			name: "wrong signature with the plain secret",
+			headers: func(body string) map[string]string {
+				return map[string]string{"X-Hub-Signature-256": signPayload("another-secret", body), "Authorization": testWebhookSecret}
+			},
+			plainSecret: true,
+			want:        false,
+		},
+		{
+			name:        "nothing",
+			headers:     func(string) map[string]string { return nil },
+			plainSecret: true,
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		for payloadName, body := range payloads {
+			t.Run(tt.name+"/"+payloadName, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodPost, "/hooks/registry", strings.NewReader(body))
+				for name, value := range tt.headers(body) {
+					r.Header.Set(name, value)
+				}
+
+				if got := verifyRegistrySignature(r, []byte(body), testWebhookSecret, tt.plainSecret); got != tt.want {
+					t.Errorf("verifyRegistrySignature() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
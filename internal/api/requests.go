@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+	"github.com/kunalsin9h/upkube/views"
+	"github.com/pkg/errors"
+)
+
+const defaultRequestsLimit = 100
+
+func (c *ServerConfig) ChangeRequests(w http.ResponseWriter, r *http.Request) {
+	userEmail := identityFrom(r).Email
+
+	status := r.URL.Query().Get("status")
+
+	requests, err := c.Store.ListChangeRequests(status, defaultRequestsLimit)
+	if err != nil {
+		log.Errorf("Failed to list change requests: %v", err)
+		http.Error(w, "Failed to list change requests: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	requests = slices.DeleteFunc(requests, func(request store.ChangeRequest) bool {
+		return !c.allowed(r, request.Namespace, policy.VerbView)
+	})
+
+	root := views.Root(views.ChangeRequests(userEmail, requests, status))
+	root.Render(r.Context(), w)
+}
+
+func (c *ServerConfig) ApproveChangeRequest(w http.ResponseWriter, r *http.Request) {
+	c.reviewChangeRequest(w, r, true)
+}
+
+func (c *ServerConfig) RejectChangeRequest(w http.ResponseWriter, r *http.Request) {
+	c.reviewChangeRequest(w, r, false)
+}
+
+func (c *ServerConfig) reviewChangeRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	userEmail := identityFrom(r).Email
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid change request id", http.StatusBadRequest)
+		return
+	}
+
+	request, err := c.Store.GetChangeRequest(id)
+	if errors.Is(err, store.ErrRequestNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get change request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Reviewers must be allowed to update images themselves
+	if !c.allowed(r, request.Namespace, policy.VerbUpdateImage) {
+		forbidden(w, request.Namespace, policy.VerbUpdateImage)
+		return
+	}
+
+	// The change applies to the cluster it was requested in, whichever the reviewer has selected
+	cluster, ok := c.clusterNamed(request.Cluster)
+	if !ok {
+		http.Error(w, "Cluster "+request.Cluster+" of the change request is no longer configured", http.StatusConflict)
+		return
+	}
+	r = withCluster(r, cluster)
+	clientSet, err := c.clientSetIn(r, cluster)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	request, err = c.Store.ReviewChangeRequest(id, userEmail, approve)
+	switch {
+	case errors.Is(err, store.ErrRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrSelfApproval):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, store.ErrRequestExpired), errors.Is(err, store.ErrRequestNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to review change request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	activity := store.Activity{
+		User:       userEmail,
+		Action:     store.ActionReject,
+		Cluster:    request.Cluster,
+		Namespace:  request.Namespace,
+		Kind:       request.Kind,
+		Deployment: request.Deployment,
+		Container:  request.Container,
+		Revision:   request.Revision,
+		OldImage:   request.OldImage,
+		NewImage:   request.NewImage,
+	}
+
+	if !approve {
+		c.recordActivity(activity, nil)
+		http.Redirect(w, r, "/requests", http.StatusSeeOther)
+		return
+	}
+
+	activity.Action = store.ActionApprove
+	kind, err := kubeapi.ParseWorkloadKind(request.Kind)
+	if err == nil {
+		// The workload may have been opted out since the request was created
+		err = c.checkWorkloadAction(clientSet, kind, request.Namespace, request.Deployment, kubeapi.AllowImageUpdateAnnotation)
+	}
+	if err == nil && request.Revision != 0 {
+		err = kubeapi.RollbackDeployment(clientSet, request.Namespace, request.Deployment, request.Revision)
+	} else if err == nil {
+		err = c.updateImage(cluster, clientSet, kind, request.Namespace, request.Deployment, request.Container, request.OldImage, request.NewImage, request.RequestedBy)
+	}
+	c.recordActivity(activity, err)
+	if err != nil {
+		if failErr := c.Store.FailChangeRequest(request.ID, err); failErr != nil {
+			log.Errorf("Failed to mark change request as failed: %v", failErr)
+		}
+		http.Error(w, "Failed to update image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redirectToRollout(w, r, kind, request.Namespace, request.Deployment, "/requests")
+}
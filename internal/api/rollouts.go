@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/views"
+)
+
+// Rollout follows the rollout of a Deployment, the page refreshes itself until it completes or fails.
+func (c *ServerConfig) Rollout(w http.ResponseWriter, r *http.Request) {
+	identity := identityFrom(r)
+	namespace := r.PathValue("namespace")
+	deployment := r.PathValue("name")
+
+	if !c.allowed(r, namespace, policy.VerbView) {
+		forbidden(w, namespace, policy.VerbView)
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.checkManagedIn(cache, kubeapi.KindDeployment, namespace, deployment); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	status, err := kubeapi.GetRolloutStatus(cache, namespace, deployment)
+	if err != nil {
+		log.Errorf("Failed to get rollout status: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return
+	}
+
+	root := views.Root(views.Rollout(identity.Email, namespace, deployment, status))
+	root.Render(r.Context(), w)
+}
+
+// redirectToRollout sends the user to the rollout page of a Deployment after changing it,
+// other kinds go to fallback.
+func redirectToRollout(w http.ResponseWriter, r *http.Request, kind kubeapi.WorkloadKind, namespace, name, fallback string) {
+	if kind != kubeapi.KindDeployment {
+		http.Redirect(w, r, fallback, http.StatusSeeOther)
+		return
+	}
+
+	target := "/rollouts/" + url.PathEscape(namespace) + "/" + url.PathEscape(name)
+	// The rollout may be in another cluster than the selected one, e.g. after approving a change request
+	if cluster, ok := r.Context().Value(clusterKey{}).(*kubeapi.Cluster); ok && cluster.Name != "" {
+		target += "?cluster=" + url.QueryEscape(cluster.Name)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// ScaleDeployment changes the replicas of a Deployment, within the bounds of its namespace.
+func (c *ServerConfig) ScaleDeployment(w http.ResponseWriter, r *http.Request) {
+	namespace := r.FormValue("namespace")
+	deployment := r.FormValue("deployment")
+	replicas, err := strconv.ParseInt(r.FormValue("replicas"), 10, 32)
+	if namespace == "" || deployment == "" || err != nil {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.scaleDeployment(r, namespace, deployment, int32(replicas)); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
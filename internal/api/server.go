@@ -3,15 +3,56 @@ package api
 import (
 	"github.com/pkg/errors"
 	"net/http"
+	"slices"
+	"sync"
+	"time"
 
-	"k8s.io/client-go/kubernetes"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
 )
 
 type ServerConfig struct {
-	Host      string
-	Port      string
-	Env       string
-	ClientSet *kubernetes.Clientset
+	Host  string
+	Port  string
+	Env   string
+	Store *store.Store
+	// Clusters upkube manages, the first one is the default. Users switch between them with the
+	// cluster selector, API clients with a cluster query parameter or X-Upkube-Cluster header.
+	Clusters []*kubeapi.Cluster
+	// Validates Cloudflare Access tokens, when nil the email header is trusted
+	AccessVerifier *oidc.IDTokenVerifier
+	// When Impersonate is set, Kubernetes calls are made as the authenticated user, using the cluster RestConfig
+	Impersonate bool
+
+	// Settings are read through settings() while serving, since a config file reload replaces them
+	Settings
+	settingsMu sync.RWMutex
+}
+
+// Settings is the part of the configuration a config file reload applies, without restarting upkube.
+type Settings struct {
+	// Image updates in protected namespaces must be approved by a second user
+	ProtectedNamespaces []string
+	RequestTTL          time.Duration
+	// Namespaces and verbs allowed per user, when nil everything is allowed
+	Policy *policy.Policy
+	// Replicas a Deployment can be scaled to, per namespace
+	ScaleBounds policy.ScaleBounds
+	// Image updates of Deployments in these namespaces, or annotated, are rolled back when their pods fail within GuardWindow
+	GuardedNamespaces []string
+	GuardWindow       time.Duration
+	// Registry pushes are rolled out to annotated Deployments, when the secret verifying them is set
+	RegistryWebhookSecret string
+	// Registries that can not sign webhooks send the secret itself, only accepted when set
+	RegistryWebhookPlainSecret bool
+	// Namespaces listed instead of listing them, for service accounts not allowed to
+	Namespaces []string
+	// Namespaces hidden from every user and rejected by every action
+	NamespaceFilter policy.NamespaceFilter
+	// When ManagedOnly is set, only workloads labeled upkube.io/managed=true are shown and acted on
+	ManagedOnly bool
 }
 
 type ServerConfigFunc func(cfg *ServerConfig)
@@ -34,9 +75,82 @@ func WithEnv(env string) ServerConfigFunc {
 	}
 }
 
-func NewServiceConfig(clientSet *kubernetes.Clientset, funcs ...ServerConfigFunc) *ServerConfig {
+func WithStore(store *store.Store) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.Store = store
+	}
+}
+
+func WithProtectedNamespaces(namespaces []string) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.ProtectedNamespaces = namespaces
+	}
+}
+
+func WithRequestTTL(ttl time.Duration) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.RequestTTL = ttl
+	}
+}
+
+func WithAccessVerifier(verifier *oidc.IDTokenVerifier) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.AccessVerifier = verifier
+	}
+}
+
+func WithPolicy(policy *policy.Policy) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.Policy = policy
+	}
+}
+
+func WithImpersonation(impersonate bool) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.Impersonate = impersonate
+	}
+}
+
+func WithScaleBounds(bounds policy.ScaleBounds) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.ScaleBounds = bounds
+	}
+}
+
+func WithGuardedUpdates(namespaces []string, window time.Duration) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.GuardedNamespaces = namespaces
+		config.GuardWindow = window
+	}
+}
+
+func WithRegistryWebhook(secret string, plainSecret bool) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.RegistryWebhookSecret = secret
+		config.RegistryWebhookPlainSecret = plainSecret
+	}
+}
+
+func WithNamespaces(namespaces []string, filter policy.NamespaceFilter) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.Namespaces = namespaces
+		config.NamespaceFilter = filter
+	}
+}
+
+func WithManagedOnly(managedOnly bool) ServerConfigFunc {
+	return func(config *ServerConfig) {
+		config.ManagedOnly = managedOnly
+	}
+}
+
+func NewServiceConfig(clusters []*kubeapi.Cluster, funcs ...ServerConfigFunc) *ServerConfig {
 	config := &ServerConfig{
-		ClientSet: clientSet,
+		Clusters: clusters,
+		Settings: Settings{
+			RequestTTL:  24 * time.Hour,
+			GuardWindow: 5 * time.Minute,
+		},
 	}
 
 	for _, fn := range funcs {
@@ -46,19 +160,72 @@ func NewServiceConfig(clientSet *kubernetes.Clientset, funcs ...ServerConfigFunc
 	return config
 }
 
+// settings returns the current settings, requests read them once so a reload does not change them halfway.
+func (c *ServerConfig) settings() Settings {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+
+	return c.Settings
+}
+
+// Reload replaces the settings, for the requests that start after it.
+func (c *ServerConfig) Reload(settings Settings) {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+
+	c.Settings = settings
+}
+
+func (c *ServerConfig) isProtected(namespace string) bool {
+	return slices.Contains(c.settings().ProtectedNamespaces, namespace)
+}
+
 func StartHttpServer(config *ServerConfig) error {
 	mux := http.NewServeMux()
 
 	// Heath check endpoint
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-
-	// Application endpoints
-	mux.HandleFunc("GET /", config.WebHome)
-	mux.HandleFunc("POST /restart", config.RestartDeployment)
-	mux.HandleFunc("POST /update-image", config.UpdateDeploymentImage)
+	mux.HandleFunc("GET /health", config.Health)
+
+	// Registries authenticate with the webhook secret, instead of a user
+	mux.HandleFunc("POST /hooks/registry", config.RegistryWebhook)
+
+	// Application endpoints, all of them require an authenticated user
+	app := http.NewServeMux()
+	app.HandleFunc("GET /", config.WebHome)
+	app.HandleFunc("GET /events/stream", config.EventStream)
+	app.HandleFunc("POST /restart", config.RestartDeployment)
+	app.HandleFunc("POST /update-image", config.UpdateDeploymentImage)
+	app.HandleFunc("POST /scale", config.ScaleDeployment)
+	app.HandleFunc("POST /pods/delete", config.DeletePod)
+	app.HandleFunc("GET /rollouts/{namespace}/{name}", config.Rollout)
+	app.HandleFunc("GET /history/{namespace}/{name}", config.RevisionHistory)
+	app.HandleFunc("GET /workloads/{namespace}/{name}", config.WorkloadDetail)
+	app.HandleFunc("GET /workloads/{namespace}/{name}/logs", config.PodLogs)
+	app.HandleFunc("GET /workloads/{namespace}/{name}/logs/stream", config.StreamPodLogs)
+	app.HandleFunc("POST /rollback", config.RollbackDeployment)
+	app.HandleFunc("GET /cronjobs", config.CronJobs)
+	app.HandleFunc("POST /cronjobs/run", config.RunCronJob)
+	app.HandleFunc("POST /cronjobs/suspend", config.SuspendCronJob)
+	app.HandleFunc("GET /logs", config.ActivityLogs)
+	app.HandleFunc("GET /requests", config.ChangeRequests)
+	app.HandleFunc("POST /requests/{id}/approve", config.ApproveChangeRequest)
+	app.HandleFunc("POST /requests/{id}/reject", config.RejectChangeRequest)
+	app.HandleFunc("GET /admin/tokens", config.APITokens)
+	app.HandleFunc("POST /admin/tokens", config.CreateAPIToken)
+	app.HandleFunc("POST /admin/tokens/{id}/revoke", config.RevokeAPIToken)
+
+	// JSON API, same authorization and activity log as the forms above
+	app.HandleFunc("GET /api/v1/clusters", config.APIListClusters)
+	app.HandleFunc("GET /api/v1/namespaces", config.APIListNamespaces)
+	app.HandleFunc("GET /api/v1/namespaces/{namespace}/workloads", config.APIListWorkloads)
+	app.HandleFunc("GET /api/v1/namespaces/{namespace}/workloads/{kind}/{name}", config.APIGetWorkload)
+	app.HandleFunc("POST /api/v1/namespaces/{namespace}/workloads/{kind}/{name}/restart", config.APIRestartWorkload)
+	app.HandleFunc("POST /api/v1/namespaces/{namespace}/workloads/{kind}/{name}/image", config.APIUpdateImage)
+	app.HandleFunc("POST /api/v1/namespaces/{namespace}/workloads/{kind}/{name}/scale", config.APIScaleDeployment)
+	app.HandleFunc("POST /api/v1/namespaces/{namespace}/workloads/{kind}/{name}/rollback", config.APIRollbackDeployment)
+	app.HandleFunc("GET /api/", config.APINotFound)
+	app.HandleFunc("POST /api/", config.APINotFound)
+	mux.Handle("/", config.Authenticate(config.SelectCluster(app)))
 
 	err := http.ListenAndServe(config.Host+":"+config.Port, mux)
 	if err != nil {
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/views"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// Changes within this window are sent as one card, a rollout updates pods many times a second
+	streamDebounce  = 500 * time.Millisecond
+	streamKeepAlive = 30 * time.Second
+)
+
+// EventStream pushes re-rendered workload cards of a namespace as Server-Sent Events.
+// A "card" event carries the HTML of a card, a "remove" event the id of a deleted one.
+func (c *ServerConfig) EventStream(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		http.Error(w, "Missing parameters", http.StatusBadRequest)
+		return
+	}
+	if !c.allowed(r, namespace, policy.VerbView) {
+		forbidden(w, namespace, policy.VerbView)
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := kubeapi.WatchWorkloads(r.Context(), cache, namespace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	flusher := http.NewResponseController(w)
+	if err := flusher.Flush(); err != nil {
+		log.Errorf("Event stream can not be flushed: %v", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changes.Done():
+			return
+		case <-changes.Ready():
+			if debounce == nil {
+				debounce = time.After(streamDebounce)
+			}
+			continue
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-debounce:
+			debounce = nil
+			for _, ref := range changes.Drain() {
+				if err := c.writeCardEvent(w, r, cache, namespace, ref); err != nil {
+					log.Warnf("Failed to send card of %s %s: %v", ref.Kind, ref.Name, err)
+				}
+			}
+		}
+
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *ServerConfig) writeCardEvent(w http.ResponseWriter, r *http.Request, cache *kubeapi.Cache, namespace string, ref kubeapi.WorkloadRef) error {
+	workload, err := kubeapi.GetWorkload(cache, ref.Kind, namespace, ref.Name)
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		writeEvent(w, "remove", views.WorkloadCardID(ref.Kind, ref.Name))
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// A workload losing the managed label disappears like a deleted one, and one gaining it shows up
+	if c.checkManaged(ref.Kind, workload.ObjectMeta) != nil {
+		writeEvent(w, "remove", views.WorkloadCardID(ref.Kind, ref.Name))
+		return nil
+	}
+
+	autoscaler := ""
+	var hpaErr error
+	if workload.Kind == kubeapi.KindDeployment {
+		autoscaler, hpaErr = kubeapi.GetDeploymentHPA(cache.ClientSet(), namespace, workload.Name)
+		if hpaErr != nil {
+			log.Warnf("Failed to find horizontalpodautoscaler: %v", hpaErr)
+		}
+	}
+
+	var card bytes.Buffer
+	err = views.DeploymentCard(*workload, cache, c.settings().ScaleBounds.For(namespace), autoscaler, hpaErr).Render(r.Context(), &card)
+	if err != nil {
+		return err
+	}
+
+	writeEvent(w, "card", card.String())
+	return nil
+}
+
+// writeEvent writes a Server-Sent Event, every line of data needs its own "data:" field.
+func writeEvent(w http.ResponseWriter, event, data string) {
+	fmt.Fprintf(w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/internal/store"
+	"github.com/kunalsin9h/upkube/views"
+	"github.com/pkg/errors"
+)
+
+// tokenVerbs are the verbs an API token can be granted, in the order the form lists them.
+var tokenVerbs = []policy.Verb{policy.VerbView, policy.VerbRestart, policy.VerbUpdateImage, policy.VerbScale, policy.VerbAll}
+
+// Token names become the user of their calls, e.g. "token:deploy-bot", so they are kept to a safe charset.
+var tokenNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+var errNotAdmin = newActionError(http.StatusForbidden, "Forbidden: only admins can manage API tokens, they are listed in the admins of the policy file.")
+
+func (c *ServerConfig) APITokens(w http.ResponseWriter, r *http.Request) {
+	if !c.isAdmin(r) {
+		writeError(w, errNotAdmin)
+		return
+	}
+
+	c.renderAPITokens(w, r, http.StatusOK, "", "")
+}
+
+// renderAPITokens renders the tokens page, with the token just created or the error of the create form.
+func (c *ServerConfig) renderAPITokens(w http.ResponseWriter, r *http.Request, status int, createdToken, formError string) {
+	tokens, err := c.Store.ListAPITokens()
+	if err != nil {
+		log.Errorf("Failed to list api tokens: %v", err)
+		http.Error(w, "Failed to list api tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The page may carry a token that is never shown again
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	root := views.Root(views.APITokens(identityFrom(r).Email, tokens, tokenVerbs, createdToken, formError))
+	root.Render(r.Context(), w)
+}
+
+func (c *ServerConfig) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	if !c.isAdmin(r) {
+		writeError(w, errNotAdmin)
+		return
+	}
+
+	token, err := parseAPITokenForm(r)
+	if err != nil {
+		c.renderAPITokens(w, r, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	token.CreatedBy = identityFrom(r).Email
+
+	plain, token, err := c.Store.CreateAPIToken(token)
+	if errors.Is(err, store.ErrTokenNameTaken) {
+		c.renderAPITokens(w, r, http.StatusConflict, "", err.Error())
+		return
+	}
+	c.recordActivity(store.Activity{
+		User:   token.CreatedBy,
+		Action: store.ActionCreateToken,
+		Token:  token.Name,
+	}, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.renderAPITokens(w, r, http.StatusOK, plain, "")
+}
+
+// parseAPITokenForm reads the scope of a new token. Namespaces and workloads are comma separated glob patterns,
+// workloads default to all of them, and the expiry is in days with 0 for a token that never expires.
+func parseAPITokenForm(r *http.Request) (store.APIToken, error) {
+	if err := r.ParseForm(); err != nil {
+		return store.APIToken{}, err
+	}
+
+	token := store.APIToken{
+		Name:        strings.TrimSpace(r.FormValue("name")),
+		Namespaces:  splitPatterns(r.FormValue("namespaces")),
+		Deployments: splitPatterns(r.FormValue("deployments")),
+		Verbs:       r.Form["verbs"],
+	}
+	if !tokenNamePattern.MatchString(token.Name) {
+		return store.APIToken{}, errors.New("name must be lowercase letters, digits, '.', '_' or '-'")
+	}
+	if len(token.Namespaces) == 0 {
+		return store.APIToken{}, errors.New("at least one namespace is required")
+	}
+	if len(token.Deployments) == 0 {
+		token.Deployments = []string{"*"}
+	}
+	for _, pattern := range slices.Concat(token.Namespaces, token.Deployments) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return store.APIToken{}, errors.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	if len(token.Verbs) == 0 {
+		return store.APIToken{}, errors.New("at least one verb is required")
+	}
+	for _, verb := range token.Verbs {
+		if !slices.Contains(tokenVerbs, policy.Verb(verb)) {
+			return store.APIToken{}, errors.Errorf("unknown verb %q", verb)
+		}
+	}
+
+	if expiresIn := r.FormValue("expiresIn"); expiresIn != "" {
+		days, err := strconv.Atoi(expiresIn)
+		if err != nil || days < 0 {
+			return store.APIToken{}, errors.New("expiry must be a number of days")
+		}
+		if days > 0 {
+			token.ExpiresAt = time.Now().AddDate(0, 0, days)
+		}
+	}
+
+	return token, nil
+}
+
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+func (c *ServerConfig) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	if !c.isAdmin(r) {
+		writeError(w, errNotAdmin)
+		return
+	}
+	userEmail := identityFrom(r).Email
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid api token id", http.StatusBadRequest)
+		return
+	}
+
+	token, err := c.Store.RevokeAPIToken(id, userEmail)
+	if errors.Is(err, store.ErrTokenNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	c.recordActivity(store.Activity{
+		User:   userEmail,
+		Action: store.ActionRevokeToken,
+		Token:  token.Name,
+	}, err)
+	if err != nil {
+		http.Error(w, "Failed to revoke api token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/tokens", http.StatusSeeOther)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/kunalsin9h/upkube/views"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// WorkloadDetail shows a workload with the Events involving it, its ReplicaSets and its pods.
+func (c *ServerConfig) WorkloadDetail(w http.ResponseWriter, r *http.Request) {
+	identity := identityFrom(r)
+	namespace := r.PathValue("namespace")
+	name := r.PathValue("name")
+
+	kind, err := kubeapi.ParseWorkloadKind(r.URL.Query().Get("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !c.allowed(r, namespace, policy.VerbView) {
+		forbidden(w, namespace, policy.VerbView)
+		return
+	}
+
+	cache, err := c.cacheFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	workload, err := kubeapi.GetWorkload(cache, kind, namespace, name)
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Errorf("Failed to get workload: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return
+	}
+	if err := c.checkManaged(kind, workload.ObjectMeta); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	pods, err := kubeapi.ListWorkloadPods(cache, namespace, workload.Selector)
+	if err != nil {
+		log.Errorf("Failed to list pods: %v", err)
+		views.Root(views.KubeError()).Render(r.Context(), w)
+		return
+	}
+
+	// The workload is still shown when events are not allowed
+	eventsError := ""
+	events, err := kubeapi.ListWorkloadEvents(cache, workload)
+	if err != nil {
+		log.Warnf("Failed to list events: %v", err)
+		eventsError = err.Error()
+	}
+
+	root := views.Root(views.WorkloadDetail(identity.Email, *workload, pods, events, eventsError))
+	root.Render(r.Context(), w)
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/policy"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// File is the upkube config file, in YAML, or TOML when its extension is .toml.
+// Every field is optional, the env var of a field overrides it.
+type File struct {
+	Host   string `json:"host,omitempty"`
+	Port   string `json:"port,omitempty"`
+	Env    string `json:"env,omitempty"`
+	DBPath string `json:"dbPath,omitempty"`
+	// Kubeconfig context and master URL in DEV
+	KubeContext string `json:"kubeContext,omitempty"`
+	MasterURL   string `json:"masterURL,omitempty"`
+	// Clusters managed from one upkube, like a clusters file
+	Clusters []kubeapi.ClusterConfig `json:"clusters,omitempty"`
+
+	CloudflareAccess CloudflareAccess `json:"cloudflareAccess,omitempty"`
+	Impersonate      *bool            `json:"impersonate,omitempty"`
+
+	// The fields below are applied on reload, the ones above on restart
+	ProtectedNamespaces []string       `json:"protectedNamespaces,omitempty"`
+	RequestTTL          string         `json:"requestTTL,omitempty"`
+	Policy              *policy.Policy `json:"policy,omitempty"`
+	// Namespace=min:max replicas, e.g. "prod-*=2:20"
+	ScaleBounds           []string `json:"scaleBounds,omitempty"`
+	GuardedNamespaces     []string `json:"guardedNamespaces,omitempty"`
+	GuardWindow           string   `json:"guardWindow,omitempty"`
+	RegistryWebhookSecret string   `json:"registryWebhookSecret,omitempty"`
+	// Accept the secret itself as the Authorization header, instead of a signature
+	RegistryWebhookPlainSecret *bool `json:"registryWebhookPlainSecret,omitempty"`
+	// Namespaces shown instead of listing them, and the glob patterns of the namespaces shown and hidden
+	Namespaces        []string `json:"namespaces,omitempty"`
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	// Only show and act on workloads labeled upkube.io/managed=true
+	ManagedOnly *bool `json:"managedOnly,omitempty"`
+}
+
+// CloudflareAccess is the Cloudflare Access application request tokens are validated for.
+type CloudflareAccess struct {
+	TeamDomain string `json:"teamDomain,omitempty"`
+	Audience   string `json:"audience,omitempty"`
+	CertsURL   string `json:"certsURL,omitempty"`
+}
+
+// Load reads and validates a config file.
+func Load(filePath string) (*File, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read config file: %s", filePath)
+	}
+
+	file, err := parse(filePath, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config file: %s", filePath)
+	}
+
+	if err := file.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid config file: %s", filePath)
+	}
+
+	return file, nil
+}
+
+// parse decodes both formats with the json tags, TOML is converted to JSON first.
+// Unknown fields are errors, so a misspelled setting is not silently ignored.
+func parse(filePath string, data []byte) (*File, error) {
+	if strings.EqualFold(filepath.Ext(filePath), ".toml") {
+		var values map[string]any
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if data, err = json.Marshal(values); err != nil {
+			return nil, err
+		}
+	}
+
+	var file File
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// Validate checks the values of the file, parsing them the same way as their env vars.
+func (f *File) Validate() error {
+	if f.Env != "" && !strings.EqualFold(f.Env, "DEV") && !strings.EqualFold(f.Env, "PROD") {
+		return fmt.Errorf("env: must be DEV or PROD, got %q", f.Env)
+	}
+
+	if len(f.Clusters) != 0 {
+		if err := kubeapi.ValidateClusterConfigs(f.Clusters); err != nil {
+			return errors.Wrap(err, "clusters")
+		}
+	}
+
+	for name, value := range map[string]string{"requestTTL": f.RequestTTL, "guardWindow": f.GuardWindow} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s: invalid duration %q", name, value)
+		}
+	}
+
+	if f.Policy != nil {
+		if err := f.Policy.Validate(); err != nil {
+			return errors.Wrap(err, "policy")
+		}
+	}
+
+	if _, err := policy.ParseScaleBounds(strings.Join(f.ScaleBounds, ",")); err != nil {
+		return errors.Wrap(err, "scaleBounds")
+	}
+
+	if _, err := policy.NewNamespaceFilter(f.IncludeNamespaces, f.ExcludeNamespaces); err != nil {
+		return errors.Wrap(err, "includeNamespaces or excludeNamespaces")
+	}
+
+	return nil
+}
+
+// RestartRequired reports whether fields only applied on restart differ from previous.
+func (f *File) RestartRequired(previous *File) bool {
+	return !reflect.DeepEqual(f.restartFields(), previous.restartFields())
+}
+
+func (f *File) restartFields() File {
+	return File{
+		Host:             f.Host,
+		Port:             f.Port,
+		Env:              f.Env,
+		DBPath:           f.DBPath,
+		KubeContext:      f.KubeContext,
+		MasterURL:        f.MasterURL,
+		Clusters:         f.Clusters,
+		CloudflareAccess: f.CloudflareAccess,
+		Impersonate:      f.Impersonate,
+	}
+}
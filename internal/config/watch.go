@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// watchDebounce groups the events of one change, editors and ConfigMap updates write in several steps.
+const watchDebounce = 500 * time.Millisecond
+
+// Watch calls onChange with the reloaded file each time the config file changes, until stop is closed.
+// A file that fails to load is passed as the error, so the previous config stays in use.
+//
+// The directory is watched instead of the file, since a mounted ConfigMap is updated by swapping
+// a symlink of the directory, which replaces the file without writing to it.
+func Watch(filePath string, stop <-chan struct{}, onChange func(*File, error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create config file watcher")
+	}
+	if err := watcher.Add(filepath.Dir(filePath)); err != nil {
+		watcher.Close()
+		return errors.Wrapf(err, "failed to watch config file: %s", filePath)
+	}
+
+	previous, _ := os.ReadFile(filePath)
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-stop:
+				return
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warnf("Config file watcher error: %v", err)
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				debounce = time.After(watchDebounce)
+			case <-debounce:
+				debounce = nil
+
+				// Events of other files in the directory, or writes that did not change it, are ignored
+				data, err := os.ReadFile(filePath)
+				if err == nil && bytes.Equal(data, previous) {
+					continue
+				}
+				previous = data
+
+				onChange(Load(filePath))
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package kubeapi
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const defaultResync = 10 * time.Minute
+
+// Cache serves Deployments, ReplicaSets, Pods and Namespaces from shared informers,
+// so rendering a page does not hit the apiserver once per card.
+// Until an informer has synced, and for a Cache created by LiveCache, reads go to the apiserver.
+type Cache struct {
+	clientSet *kubernetes.Clientset
+	factory   informers.SharedInformerFactory
+
+	deployments appslisters.DeploymentLister
+	replicaSets appslisters.ReplicaSetLister
+	pods        corelisters.PodLister
+	namespaces  corelisters.NamespaceLister
+
+	informers map[string]cache.SharedIndexInformer
+}
+
+func NewCache(clientSet *kubernetes.Clientset) *Cache {
+	factory := informers.NewSharedInformerFactory(clientSet, defaultResync)
+
+	deployments := factory.Apps().V1().Deployments()
+	replicaSets := factory.Apps().V1().ReplicaSets()
+	pods := factory.Core().V1().Pods()
+	namespaces := factory.Core().V1().Namespaces()
+
+	return &Cache{
+		clientSet:   clientSet,
+		factory:     factory,
+		deployments: deployments.Lister(),
+		replicaSets: replicaSets.Lister(),
+		pods:        pods.Lister(),
+		namespaces:  namespaces.Lister(),
+		informers: map[string]cache.SharedIndexInformer{
+			"deployments": deployments.Informer(),
+			"replicasets": replicaSets.Informer(),
+			"pods":        pods.Informer(),
+			"namespaces":  namespaces.Informer(),
+		},
+	}
+}
+
+// LiveCache reads everything from the apiserver, it is used for impersonated clients,
+// whose view of the cluster can not be shared.
+func LiveCache(clientSet *kubernetes.Clientset) *Cache {
+	return &Cache{clientSet: clientSet}
+}
+
+// Start runs the informers until stopCh is closed, it does not wait for them to sync.
+func (c *Cache) Start(stopCh <-chan struct{}) {
+	if c.factory != nil {
+		c.factory.Start(stopCh)
+	}
+}
+
+func (c *Cache) ClientSet() *kubernetes.Clientset {
+	return c.clientSet
+}
+
+// SyncState tells, for every informer, whether its initial list has completed.
+func (c *Cache) SyncState() map[string]bool {
+	state := make(map[string]bool, len(c.informers))
+	for name, informer := range c.informers {
+		state[name] = informer.HasSynced()
+	}
+
+	return state
+}
+
+func (c *Cache) synced(name string) bool {
+	informer, ok := c.informers[name]
+	return ok && informer.HasSynced()
+}
+
+// ListDeployments lists the Deployments of a namespace matching selector, see ManagedSelector.
+func (c *Cache) ListDeployments(namespace string, selector labels.Selector) ([]appsv1.Deployment, error) {
+	if !c.synced("deployments") {
+		deployments, err := c.clientSet.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list deployments in namespace: %s", namespace)
+		}
+		return deployments.Items, nil
+	}
+
+	deployments, err := c.deployments.Deployments(namespace).List(selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list deployments in namespace: %s", namespace)
+	}
+
+	return values(deployments), nil
+}
+
+func (c *Cache) GetDeployment(namespace, name string) (*appsv1.Deployment, error) {
+	if !c.synced("deployments") {
+		deployment, err := c.clientSet.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get deployment")
+		}
+		return deployment, nil
+	}
+
+	deployment, err := c.deployments.Deployments(namespace).Get(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deployment")
+	}
+
+	return deployment.DeepCopy(), nil
+}
+
+func (c *Cache) ListReplicaSets(namespace string, selector labels.Selector) ([]appsv1.ReplicaSet, error) {
+	if !c.synced("replicasets") {
+		replicaSets, err := c.clientSet.AppsV1().ReplicaSets(namespace).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list replicasets in namespace: %s", namespace)
+		}
+		return replicaSets.Items, nil
+	}
+
+	replicaSets, err := c.replicaSets.ReplicaSets(namespace).List(selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list replicasets in namespace: %s", namespace)
+	}
+
+	return values(replicaSets), nil
+}
+
+func (c *Cache) ListPods(namespace string, selector labels.Selector) ([]corev1.Pod, error) {
+	if !c.synced("pods") {
+		pods, err := c.clientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list pods in namespace: %s", namespace)
+		}
+		return pods.Items, nil
+	}
+
+	pods, err := c.pods.Pods(namespace).List(selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pods in namespace: %s", namespace)
+	}
+
+	return values(pods), nil
+}
+
+func (c *Cache) ListNamespaces() ([]corev1.Namespace, error) {
+	if !c.synced("namespaces") {
+		namespaces, err := c.clientSet.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list namespaces")
+		}
+		return namespaces.Items, nil
+	}
+
+	namespaces, err := c.namespaces.List(labels.Everything())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list namespaces")
+	}
+
+	return values(namespaces), nil
+}
+
+// values dereferences objects of the informer store, sorted like the apiserver lists them.
+// They still share maps and slices with the store, so they must not be mutated.
+func values[T any, P interface {
+	*T
+	metav1.Object
+}](objects []P) []T {
+	slices.SortFunc(objects, func(a, b P) int {
+		return cmp.Or(cmp.Compare(a.GetNamespace(), b.GetNamespace()), cmp.Compare(a.GetName(), b.GetName()))
+	})
+
+	items := make([]T, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, *object)
+	}
+
+	return items
+}
@@ -0,0 +1,111 @@
+package kubeapi
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// Cluster is a Kubernetes cluster upkube manages, with the clients of its service account.
+// The single cluster upkube runs with, without a clusters file, has an empty Name.
+type Cluster struct {
+	Name string
+	// Context is the kubeconfig context connected with, empty for the in-cluster config
+	Context    string
+	RestConfig *rest.Config
+	ClientSet  *kubernetes.Clientset
+	// Informer cache pages read from, when nil they read from the apiserver
+	Cache *Cache
+}
+
+// ClusterConfig defines a cluster of the clusters file, by a kubeconfig path and context or the in-cluster config.
+type ClusterConfig struct {
+	Name       string `json:"name"`
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Context of the kubeconfig, its current context when empty
+	Context   string `json:"context,omitempty"`
+	InCluster bool   `json:"inCluster,omitempty"`
+}
+
+type clustersFile struct {
+	Clusters []ClusterConfig `json:"clusters"`
+}
+
+// LoadClusterConfigs reads the clusters of a clusters file, the first one is the default.
+func LoadClusterConfigs(filePath string) ([]ClusterConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read clusters file")
+	}
+
+	var file clustersFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, errors.Wrap(err, "failed to parse clusters file")
+	}
+
+	if err := ValidateClusterConfigs(file.Clusters); err != nil {
+		return nil, errors.Wrap(err, "invalid clusters file")
+	}
+
+	return file.Clusters, nil
+}
+
+// ValidateClusterConfigs checks cluster names are unique, and each cluster has a kubeconfig or the in-cluster config.
+func ValidateClusterConfigs(configs []ClusterConfig) error {
+	if len(configs) == 0 {
+		return fmt.Errorf("at least one cluster is required")
+	}
+
+	names := map[string]bool{}
+	for i, config := range configs {
+		if config.Name == "" {
+			return fmt.Errorf("cluster %d: name is required", i)
+		}
+		if names[config.Name] {
+			return fmt.Errorf("cluster %d: duplicate name %q", i, config.Name)
+		}
+		names[config.Name] = true
+
+		if config.InCluster == (config.Kubeconfig != "") {
+			return fmt.Errorf("cluster %q: exactly one of kubeconfig or inCluster is required", config.Name)
+		}
+		if config.InCluster && config.Context != "" {
+			return fmt.Errorf("cluster %q: context is only used with a kubeconfig", config.Name)
+		}
+	}
+
+	return nil
+}
+
+// NewCluster connects to a cluster of the clusters file.
+func NewCluster(config ClusterConfig) (*Cluster, error) {
+	var restConfig *rest.Config
+	var contextName string
+	var err error
+
+	if config.InCluster {
+		restConfig, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cluster %s: failed to create in-cluster config", config.Name)
+		}
+	} else {
+		loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: config.Kubeconfig}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: config.Context}
+		restConfig, contextName, err = kubeconfigRestConfig(loadingRules, overrides)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cluster %s", config.Name)
+		}
+	}
+
+	clientSet, err := NewClientSet(restConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cluster %s", config.Name)
+	}
+
+	return &Cluster{Name: config.Name, Context: contextName, RestConfig: restConfig, ClientSet: clientSet}, nil
+}
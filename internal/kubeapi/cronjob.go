@@ -0,0 +1,79 @@
+package kubeapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ListCronJobs lists the CronJobs of a namespace matching selector, see ManagedSelector.
+func ListCronJobs(clientSet *kubernetes.Clientset, namespace string, selector labels.Selector) ([]batchv1.CronJob, error) {
+	cronJobs, err := clientSet.BatchV1().CronJobs(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list cronjobs in namespace: %s", namespace)
+	}
+
+	return cronJobs.Items, nil
+}
+
+// RunCronJob creates a Job from the jobTemplate of a CronJob, the same way `kubectl create job --from` does.
+func RunCronJob(clientSet *kubernetes.Clientset, namespace, name string) (*batchv1.Job, error) {
+	cronJob, err := clientSet.BatchV1().CronJobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cronjob")
+	}
+
+	jobName := fmt.Sprintf("%s-manual-%s", cronJob.Name, rand.String(3))
+	if len(jobName) > 63 {
+		jobName = jobName[:63]
+	}
+
+	annotations := map[string]string{"cronjob.kubernetes.io/instantiate": "manual"}
+	for k, v := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        jobName,
+			Namespace:   namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+
+	job, err = clientSet.BatchV1().Jobs(namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create job from cronjob in namespace: %s", namespace)
+	}
+
+	return job, nil
+}
+
+func SuspendCronJob(clientSet *kubernetes.Clientset, namespace, name string, suspend bool) error {
+	patch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{"suspend": suspend},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = clientSet.BatchV1().CronJobs(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to suspend cronjob in namespace: %s", namespace)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package kubeapi
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ListWorkloadEvents lists the Events involving a workload, its ReplicaSets when it is a Deployment, and its pods,
+// newest first. They explain what the pods can not, e.g. a pod that can not be scheduled has no container state.
+func ListWorkloadEvents(cache *Cache, workload *Workload) ([]corev1.Event, error) {
+	involved := map[string]bool{string(workload.Kind) + "/" + workload.Name: true}
+
+	if workload.Kind == KindDeployment {
+		deployment, err := cache.GetDeployment(workload.Namespace, workload.Name)
+		if err != nil {
+			return nil, err
+		}
+		replicaSets, err := ownedReplicaSets(cache, deployment)
+		if err != nil {
+			return nil, err
+		}
+		for _, replicaSet := range replicaSets {
+			involved["ReplicaSet/"+replicaSet.Name] = true
+		}
+	}
+
+	pods, err := ListWorkloadPods(cache, workload.Namespace, workload.Selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		involved["Pod/"+pod.Name] = true
+	}
+
+	// Events can not be selected by several objects at once, the namespace is listed and filtered instead
+	events, err := cache.ClientSet().CoreV1().Events(workload.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list events in namespace: %s", workload.Namespace)
+	}
+
+	items := slices.DeleteFunc(events.Items, func(event corev1.Event) bool {
+		return !involved[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name]
+	})
+	slices.SortFunc(items, func(a, b corev1.Event) int {
+		return cmp.Compare(EventTime(b).UnixNano(), EventTime(a).UnixNano())
+	})
+
+	return items, nil
+}
+
+// EventTime is when an event was last seen, Events of the events.k8s.io API only set EventTime.
+func EventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+
+	return event.CreationTimestamp.Time
+}
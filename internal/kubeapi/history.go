@@ -0,0 +1,147 @@
+package kubeapi
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	RevisionAnnotation    = "deployment.kubernetes.io/revision"
+	ChangeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// Revision is a rollout of a Deployment, as recorded by one of its ReplicaSets.
+type Revision struct {
+	Number      int64
+	ReplicaSet  string
+	Created     time.Time
+	ChangeCause string
+	Template    corev1.PodTemplateSpec
+	Current     bool
+}
+
+// ContainerImages lists the images of a pod template as "container=image", init containers first.
+func ContainerImages(template corev1.PodTemplateSpec) string {
+	var images []string
+	for _, container := range slices.Concat(template.Spec.InitContainers, template.Spec.Containers) {
+		images = append(images, container.Name+"="+container.Image)
+	}
+
+	return strings.Join(images, ", ")
+}
+
+// ListDeploymentRevisions builds the revision history of a Deployment from the ReplicaSets it owns, newest first.
+func ListDeploymentRevisions(cache *Cache, namespace, name string) ([]Revision, error) {
+	deployment, err := cache.GetDeployment(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	replicaSets, err := ownedReplicaSets(cache, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	current := deployment.Annotations[RevisionAnnotation]
+
+	var revisions []Revision
+	for _, replicaSet := range replicaSets {
+		number, err := strconv.ParseInt(replicaSet.Annotations[RevisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		revisions = append(revisions, Revision{
+			Number:      number,
+			ReplicaSet:  replicaSet.Name,
+			Created:     replicaSet.CreationTimestamp.Time,
+			ChangeCause: replicaSet.Annotations[ChangeCauseAnnotation],
+			Template:    replicaSet.Spec.Template,
+			Current:     replicaSet.Annotations[RevisionAnnotation] == current,
+		})
+	}
+
+	slices.SortFunc(revisions, func(a, b Revision) int {
+		return cmp.Compare(b.Number, a.Number)
+	})
+
+	return revisions, nil
+}
+
+// RollbackDeployment restores the pod template of a revision, the same way `kubectl rollout undo --to-revision` does.
+func RollbackDeployment(clientSet *kubernetes.Clientset, namespace, name string, revision int64) error {
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment, getErr := clientSet.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+		if deployment.Spec.Paused {
+			return errors.New("can not rollback a paused deployment, resume it first")
+		}
+
+		replicaSets, err := ownedReplicaSets(LiveCache(clientSet), deployment)
+		if err != nil {
+			return err
+		}
+
+		target := strconv.FormatInt(revision, 10)
+		index := slices.IndexFunc(replicaSets, func(replicaSet appsv1.ReplicaSet) bool {
+			return replicaSet.Annotations[RevisionAnnotation] == target
+		})
+		if index == -1 {
+			return fmt.Errorf("revision %d not found", revision)
+		}
+		replicaSet := replicaSets[index]
+
+		template := *replicaSet.Spec.Template.DeepCopy()
+		delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+		deployment.Spec.Template = template
+
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		if cause, ok := replicaSet.Annotations[ChangeCauseAnnotation]; ok {
+			deployment.Annotations[ChangeCauseAnnotation] = cause
+		} else {
+			delete(deployment.Annotations, ChangeCauseAnnotation)
+		}
+
+		_, updateErr := clientSet.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if retryErr != nil {
+		return errors.Wrapf(retryErr, "Failed to rollback deployment to revision %d in namespace: %s", revision, namespace)
+	}
+
+	return nil
+}
+
+// ownedReplicaSets lists the ReplicaSets controlled by a Deployment.
+func ownedReplicaSets(cache *Cache, deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid deployment label selector")
+	}
+
+	replicaSets, err := cache.ListReplicaSets(deployment.Namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(replicaSets, func(replicaSet appsv1.ReplicaSet) bool {
+		owner := metav1.GetControllerOf(&replicaSet)
+		return owner == nil || owner.UID != deployment.UID
+	}), nil
+}
@@ -0,0 +1,33 @@
+package kubeapi
+
+import (
+	"path"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// TagPatternAnnotation on a Deployment opts it in to registry webhook rollouts. Its value is a glob
+// matched against pushed tags, e.g. "v1.*".
+const TagPatternAnnotation = "upkube.io/tag-pattern"
+
+// SplitImage splits an image on its last ":", into the prefix the dashboard shows and its tag.
+func SplitImage(image string) (string, string) {
+	idx := strings.LastIndex(image, ":")
+	if idx == -1 {
+		return image, ""
+	}
+
+	return image[:idx], image[idx+1:]
+}
+
+// MatchesTagPattern reports whether a Deployment is opted in to rollouts of tag.
+func MatchesTagPattern(deployment appsv1.Deployment, tag string) bool {
+	pattern := deployment.Annotations[TagPatternAnnotation]
+	if pattern == "" {
+		return false
+	}
+
+	ok, _ := path.Match(pattern, tag)
+	return ok
+}
@@ -5,38 +5,69 @@ import (
 	"fmt"
 	"github.com/charmbracelet/log"
 	"github.com/pkg/errors"
-	"path/filepath"
+	"slices"
 	"strings"
 
-	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
-	"k8s.io/client-go/util/retry"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func NewClientSet(env string) (*kubernetes.Clientset, error) {
-	var config *rest.Config
-	var err error
+// KubeconfigOptions select the cluster DEV mode connects to, on top of the kubeconfig files.
+type KubeconfigOptions struct {
+	// Context of the kubeconfig, its current context when empty
+	Context string
+	// MasterURL overrides the server of the context
+	MasterURL string
+}
 
+// NewRestConfig creates the config of the cluster upkube runs with, and returns the kubeconfig context it uses.
+// In PROD it is the in-cluster config, with no context. In DEV, kubeconfig files are read from KUBECONFIG,
+// which may list several files to merge like kubectl does, and default to ~/.kube/config.
+func NewRestConfig(env string, options KubeconfigOptions) (*rest.Config, string, error) {
 	if strings.EqualFold(env, "PROD") {
 		// Create in-cluster config
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create in-cluster config")
-		}
-	} else {
-		// Use local kubeconfig
-		kubeconfig := filepath.Join(homedir.HomeDir(), ".kube", "config")
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		config, err := rest.InClusterConfig()
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to create config from kubeconfig file")
+			return nil, "", errors.Wrap(err, "failed to create in-cluster config")
 		}
+		return config, "", nil
+	}
+
+	// Use local kubeconfig
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: options.Context}
+	overrides.ClusterInfo.Server = options.MasterURL
+
+	return kubeconfigRestConfig(loadingRules, overrides)
+}
+
+// kubeconfigRestConfig creates the config of a kubeconfig context, and returns the name of the context.
+func kubeconfigRestConfig(loadingRules *clientcmd.ClientConfigLoadingRules, overrides *clientcmd.ConfigOverrides) (*rest.Config, string, error) {
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to load kubeconfig file")
+	}
+	contextName := overrides.CurrentContext
+	if contextName == "" {
+		contextName = rawConfig.CurrentContext
 	}
 
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create config from kubeconfig file")
+	}
+
+	return config, contextName, nil
+}
+
+func NewClientSet(config *rest.Config) (*kubernetes.Clientset, error) {
 	// Create clientSet
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -46,115 +77,130 @@ func NewClientSet(env string) (*kubernetes.Clientset, error) {
 	return clientSet, nil
 }
 
-func GetAllNameSpaces(clientSet *kubernetes.Clientset) ([]string, error) {
-	namespaces, err := clientSet.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		log.Warnf("Failed to list namespaces, permission not allowed. %v", err)
-		//return nil, fmt.Errorf("failed to list namespaces: %v", err)
-		// Hypothesis: is service account is in default namespaces, we might not access other namespaces
-		return []string{"default"}, nil // Return default namespace if listing fails
+// NewImpersonatedClientSet creates a clientSet acting as user and groups,
+// so the cluster RBAC decides what they can do and the apiserver audit log names them.
+func NewImpersonatedClientSet(config *rest.Config, user string, groups []string) (*kubernetes.Clientset, error) {
+	config = rest.CopyConfig(config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: user,
+		Groups:   groups,
+	}
+
+	return NewClientSet(config)
+}
+
+func GetAllNameSpaces(cache *Cache) ([]string, error) {
+	namespaces, err := cache.ListNamespaces()
+	if apierrors.IsForbidden(err) {
+		// Service accounts not allowed to list namespaces need them configured instead
+		return nil, errors.Wrap(err, "not allowed to list namespaces, configure the namespaces to show instead")
+	} else if err != nil {
+		return nil, err
 	}
 
 	var namespaceNames []string
-	for _, ns := range namespaces.Items {
+	for _, ns := range namespaces {
 		namespaceNames = append(namespaceNames, ns.Name)
 	}
 
 	return namespaceNames, nil
 }
 
-func ListDeployments(clientSet *kubernetes.Clientset, namespace string) (*v1.DeploymentList, error) {
-	deployments, err := clientSet.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to list deployments in namespace: %s", namespace)
+func RestartWorkload(clientSet *kubernetes.Clientset, kind WorkloadKind, namespace, name string) error {
+	if kind == KindCronJob {
+		return errors.New("cronjobs can not be restarted, run them instead")
 	}
 
-	return deployments, nil
-}
-
-func RestartDeployment(clientSet *kubernetes.Clientset, namespace, deploymentName string) error {
-	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		deployment, getErr := clientSet.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
-		if getErr != nil {
-			return getErr
-		}
-		if deployment.Spec.Template.Annotations == nil {
-			deployment.Spec.Template.Annotations = map[string]string{}
+	retryErr := updatePodTemplate(clientSet, kind, namespace, name, func(template *corev1.PodTemplateSpec) error {
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
 		}
-		deployment.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = fmt.Sprintf("%v", metav1.Now())
-		_, updateErr := clientSet.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
-		return updateErr
+		template.Annotations["kubectl.kubernetes.io/restartedAt"] = fmt.Sprintf("%v", metav1.Now())
+		return nil
 	})
 	if retryErr != nil {
-		return errors.Wrapf(retryErr, "Failed to restart deployment in namespace %s", namespace)
+		return errors.Wrapf(retryErr, "Failed to restart %s in namespace %s", kind, namespace)
 	}
 
 	return nil
 }
 
-func UpdateDeploymentImage(clientSet *kubernetes.Clientset, namespace, deploymentName, newImage string) error {
-	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		deployment, getErr := clientSet.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
-		if getErr != nil {
-			return getErr
-		}
-		// Assuming the first container is the one to update
-		deployment.Spec.Template.Spec.Containers[0].Image = newImage
-		_, updateErr := clientSet.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
-		return updateErr
+// UpdateWorkloadImage sets the image of the named container, or init container, of a workload.
+func UpdateWorkloadImage(clientSet *kubernetes.Clientset, kind WorkloadKind, namespace, name, container, newImage string) error {
+	retryErr := updatePodTemplate(clientSet, kind, namespace, name, func(template *corev1.PodTemplateSpec) error {
+		return setContainerImage(&template.Spec, container, newImage)
 	})
 	if retryErr != nil {
-		return errors.Wrapf(retryErr, "Failed to update deployment image in namespace: %s", namespace)
+		return errors.Wrapf(retryErr, "Failed to update %s image in namespace: %s", kind, namespace)
 	}
 
 	return nil
 }
 
-func GetDeploymentImageError(clientSet *kubernetes.Clientset, namespace, deploymentName string) (string, string, error) {
-	// List pods with the deployment's label selector
-	deployment, err := clientSet.AppsV1().Deployments(namespace).Get(context.TODO(), deploymentName, metav1.GetOptions{})
-	if err != nil {
-		return "", "", errors.Wrap(err, "failed to get deployments")
+func setContainerImage(spec *corev1.PodSpec, container, image string) error {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == container {
+			spec.Containers[i].Image = image
+			return nil
+		}
+	}
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == container {
+			spec.InitContainers[i].Image = image
+			return nil
+		}
 	}
 
-	selector := deployment.Spec.Selector.MatchLabels
+	return fmt.Errorf("container %q not found in pod template", container)
+}
 
-	var labelSelector []string
-	for k, v := range selector {
-		labelSelector = append(labelSelector, fmt.Sprintf("%s=%s", k, v))
+// ListWorkloadPods lists the pods of a workload, using its label selector.
+func ListWorkloadPods(cache *Cache, namespace string, selector *metav1.LabelSelector) ([]corev1.Pod, error) {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid workload label selector")
 	}
 
-	pods, err := clientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: strings.Join(labelSelector, ","),
-	})
-
+	pods, err := cache.ListPods(namespace, labelSelector)
 	if err != nil {
 		log.Warn("failed to list pods, permission not granted")
-		return "", "", errors.Wrap(err, "failed to list pods for deployment")
+		return nil, errors.Wrap(err, "failed to list pods for workload")
 	}
 
-	for _, pod := range pods.Items {
-		for i, cs := range pod.Status.ContainerStatuses {
+	return pods, nil
+}
+
+// WorkloadImageError finds pods of a workload, as listed by ListWorkloadPods, stuck on pulling an image or crash looping.
+func WorkloadImageError(pods []corev1.Pod) (string, string) {
+	for _, pod := range pods {
+		statuses := slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses)
+		for _, cs := range statuses {
 			if cs.State.Waiting != nil {
 				reason := cs.State.Waiting.Reason
 
 				if reason == "ImagePullBackOff" || reason == "ErrImagePull" || reason == "CrashLoopBackOff" {
-					image := ""
-
-					if i < len(pod.Spec.Containers) {
-						image = pod.Spec.Containers[i].Image
-					}
+					image := cs.Image
 
 					msg := cs.State.Waiting.Message
 					if image != "" {
-						msg = fmt.Sprintf("Image: %s\n%s", image, msg)
+						msg = fmt.Sprintf("Container: %s\nImage: %s\n%s", cs.Name, image, msg)
 					}
 
-					return reason, msg, nil
+					return reason, msg
 				}
 			}
 		}
 	}
 
-	return "", "", nil
+	return "", ""
+}
+
+// DeletePod deletes a single pod, its controller replaces it with a new one.
+func DeletePod(clientSet *kubernetes.Clientset, namespace, name string) error {
+	err := clientSet.CoreV1().Pods(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to delete pod %s in namespace: %s", name, namespace)
+	}
+
+	return nil
 }
@@ -0,0 +1,38 @@
+package kubeapi
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LogOptions selects the container and the lines of a pod log, like the flags of `kubectl logs`.
+type LogOptions struct {
+	Container string
+	TailLines int64
+	// Previous reads the log of the last terminated container, e.g. the crash of a CrashLoopBackOff
+	Previous bool
+	Follow   bool
+}
+
+// StreamPodLogs opens the log of a pod container, a followed log is open until ctx is done or the container stops.
+func StreamPodLogs(ctx context.Context, clientSet *kubernetes.Clientset, namespace, pod string, options LogOptions) (io.ReadCloser, error) {
+	podLogOptions := &corev1.PodLogOptions{
+		Container: options.Container,
+		Previous:  options.Previous,
+		Follow:    options.Follow,
+	}
+	if options.TailLines > 0 {
+		podLogOptions.TailLines = &options.TailLines
+	}
+
+	stream, err := clientSet.CoreV1().Pods(namespace).GetLogs(pod, podLogOptions).Stream(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get logs of pod %s in namespace: %s", pod, namespace)
+	}
+
+	return stream, nil
+}
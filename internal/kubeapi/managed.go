@@ -0,0 +1,53 @@
+package kubeapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ManagedLabel set to "true" opts a workload in, when upkube only shows and acts on opted in workloads.
+const ManagedLabel = "upkube.io/managed"
+
+// Annotations set to "false" on a workload disable an action on it, whoever asks for it.
+const (
+	// AllowRestartAnnotation disables restarts, running and suspending CronJobs, and deleting pods
+	AllowRestartAnnotation = "upkube.io/allow-restart"
+	// AllowImageUpdateAnnotation disables image updates, including rollbacks and registry rollouts
+	AllowImageUpdateAnnotation = "upkube.io/allow-image-update"
+)
+
+// ManagedSelector selects the workloads upkube shows and acts on, every workload unless managedOnly is set.
+func ManagedSelector(managedOnly bool) labels.Selector {
+	if !managedOnly {
+		return labels.Everything()
+	}
+
+	return labels.SelectorFromSet(labels.Set{ManagedLabel: "true"})
+}
+
+// ActionAllowed reports whether the annotations of a workload allow the action of annotation, only "false" disables it.
+func ActionAllowed(annotations map[string]string, annotation string) bool {
+	return !strings.EqualFold(annotations[annotation], "false")
+}
+
+// GetWorkloadMeta gets the labels and annotations of a workload of any kind, including CronJobs.
+func GetWorkloadMeta(cache *Cache, kind WorkloadKind, namespace, name string) (*metav1.ObjectMeta, error) {
+	if kind == KindCronJob {
+		cronJob, err := cache.ClientSet().BatchV1().CronJobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get cronjob")
+		}
+		return &cronJob.ObjectMeta, nil
+	}
+
+	workload, err := GetWorkload(cache, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workload.ObjectMeta, nil
+}
@@ -0,0 +1,177 @@
+package kubeapi
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GuardedUpdateAnnotation set to "true" on a Deployment opts it in to guarded image updates.
+const GuardedUpdateAnnotation = "upkube.io/guarded-update"
+
+type RolloutPhase string
+
+const (
+	RolloutProgressing RolloutPhase = "Progressing"
+	RolloutComplete    RolloutPhase = "Complete"
+	RolloutFailed      RolloutPhase = "Failed"
+)
+
+// RolloutStatus is the progress of a Deployment rollout, as `kubectl rollout status` reports it.
+type RolloutStatus struct {
+	Phase   RolloutPhase
+	Message string
+
+	Generation         int64
+	ObservedGeneration int64
+	Desired            int32
+	Updated            int32
+	Ready              int32
+	Available          int32
+	// Replicas of any revision, old ones are still terminating while it is above Updated
+	Total int32
+
+	// NewReplicaSet runs the current revision of the pod template
+	NewReplicaSet      string
+	NewReplicaSetReady int32
+	NewReplicaSetWant  int32
+	// Pods of the new ReplicaSet stuck crash looping or pulling their image
+	FailingPods map[string]string
+
+	Conditions []appsv1.DeploymentCondition
+}
+
+func (s RolloutStatus) Done() bool {
+	return s.Phase != RolloutProgressing
+}
+
+// GetRolloutStatus follows the same rules as `kubectl rollout status`,
+// and also fails the rollout when pods of the new ReplicaSet are crash looping.
+func GetRolloutStatus(cache *Cache, namespace, name string) (*RolloutStatus, error) {
+	deployment, err := cache.GetDeployment(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &RolloutStatus{
+		Generation:         deployment.Generation,
+		ObservedGeneration: deployment.Status.ObservedGeneration,
+		Updated:            deployment.Status.UpdatedReplicas,
+		Ready:              deployment.Status.ReadyReplicas,
+		Available:          deployment.Status.AvailableReplicas,
+		Total:              deployment.Status.Replicas,
+		Conditions:         deployment.Status.Conditions,
+	}
+	if deployment.Spec.Replicas != nil {
+		status.Desired = *deployment.Spec.Replicas
+	}
+
+	if err := status.findNewReplicaSet(cache, deployment); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case deployment.Generation > deployment.Status.ObservedGeneration:
+		status.Phase = RolloutProgressing
+		status.Message = "Waiting for deployment spec update to be observed..."
+	case hasCondition(deployment, appsv1.DeploymentProgressing, "ProgressDeadlineExceeded"):
+		status.Phase = RolloutFailed
+		status.Message = fmt.Sprintf("deployment %q exceeded its progress deadline", name)
+	case len(status.FailingPods) > 0:
+		status.Phase = RolloutFailed
+		status.Message = fmt.Sprintf("%d pods of the new replica set %q are failing", len(status.FailingPods), status.NewReplicaSet)
+	case status.Updated < status.Desired:
+		status.Phase = RolloutProgressing
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...", name, status.Updated, status.Desired)
+	case status.Total > status.Updated:
+		status.Phase = RolloutProgressing
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...", name, status.Total-status.Updated)
+	case status.Available < status.Updated:
+		status.Phase = RolloutProgressing
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...", name, status.Available, status.Updated)
+	default:
+		status.Phase = RolloutComplete
+		status.Message = fmt.Sprintf("deployment %q successfully rolled out", name)
+	}
+
+	return status, nil
+}
+
+// findNewReplicaSet finds the ReplicaSet of the current revision, and its pods failing to start.
+func (s *RolloutStatus) findNewReplicaSet(cache *Cache, deployment *appsv1.Deployment) error {
+	replicaSets, err := ownedReplicaSets(cache, deployment)
+	if err != nil {
+		return err
+	}
+
+	revision := deployment.Annotations[RevisionAnnotation]
+	index := slices.IndexFunc(replicaSets, func(replicaSet appsv1.ReplicaSet) bool {
+		return revision != "" && replicaSet.Annotations[RevisionAnnotation] == revision
+	})
+	if index == -1 {
+		return nil
+	}
+	replicaSet := replicaSets[index]
+
+	s.NewReplicaSet = replicaSet.Name
+	s.NewReplicaSetReady = replicaSet.Status.ReadyReplicas
+	if replicaSet.Spec.Replicas != nil {
+		s.NewReplicaSetWant = *replicaSet.Spec.Replicas
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(replicaSet.Spec.Selector)
+	if err != nil {
+		return errors.Wrap(err, "invalid replicaset label selector")
+	}
+	pods, err := cache.ListPods(replicaSet.Namespace, selector)
+	if err != nil {
+		return err
+	}
+
+	s.FailingPods = map[string]string{}
+	for _, pod := range pods {
+		if reason := podFailure(pod); reason != "" {
+			s.FailingPods[pod.Name] = reason
+		}
+	}
+
+	return nil
+}
+
+func podFailure(pod corev1.Pod) string {
+	for _, cs := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+		if cs.State.Waiting == nil {
+			continue
+		}
+		switch cs.State.Waiting.Reason {
+		case "CrashLoopBackOff", "ImagePullBackOff", "ErrImagePull":
+			return fmt.Sprintf("%s: %s", cs.Name, cs.State.Waiting.Reason)
+		}
+	}
+
+	return ""
+}
+
+func hasCondition(deployment *appsv1.Deployment, conditionType appsv1.DeploymentConditionType, reason string) bool {
+	return slices.ContainsFunc(deployment.Status.Conditions, func(condition appsv1.DeploymentCondition) bool {
+		return condition.Type == conditionType && condition.Reason == reason
+	})
+}
+
+// RestorePodTemplate puts back a pod template saved before an update, e.g. when the update failed to roll out.
+func RestorePodTemplate(clientSet *kubernetes.Clientset, kind WorkloadKind, namespace, name string, template corev1.PodTemplateSpec) error {
+	retryErr := updatePodTemplate(clientSet, kind, namespace, name, func(current *corev1.PodTemplateSpec) error {
+		*current = *template.DeepCopy()
+		return nil
+	})
+	if retryErr != nil {
+		return errors.Wrapf(retryErr, "Failed to restore %s pod template in namespace: %s", kind, namespace)
+	}
+
+	return nil
+}
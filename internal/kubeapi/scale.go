@@ -0,0 +1,63 @@
+package kubeapi
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ScaleDeployment sets the replicas of a Deployment through its scale subresource, returning the previous replicas.
+func ScaleDeployment(clientSet *kubernetes.Clientset, namespace, name string, replicas int32) (int32, error) {
+	var previous int32
+
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, getErr := clientSet.AppsV1().Deployments(namespace).GetScale(context.TODO(), name, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+
+		previous = scale.Spec.Replicas
+		scale.Spec.Replicas = replicas
+
+		_, updateErr := clientSet.AppsV1().Deployments(namespace).UpdateScale(context.TODO(), name, scale, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if retryErr != nil {
+		return 0, errors.Wrapf(retryErr, "Failed to scale deployment in namespace: %s", namespace)
+	}
+
+	return previous, nil
+}
+
+// GetDeploymentHPA returns the name of the HorizontalPodAutoscaler targeting a Deployment, if any.
+func GetDeploymentHPA(clientSet *kubernetes.Clientset, namespace, name string) (string, error) {
+	autoscalers, err := ListDeploymentHPAs(clientSet, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	return autoscalers[name], nil
+}
+
+// ListDeploymentHPAs maps the Deployments of a namespace to the HorizontalPodAutoscaler targeting them.
+// Failing to list them is an error even when the service account is not allowed to, since a Deployment
+// they cannot be checked for may be owned by an autoscaler.
+func ListDeploymentHPAs(clientSet *kubernetes.Clientset, namespace string) (map[string]string, error) {
+	autoscalers, err := clientSet.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list horizontalpodautoscalers in namespace: %s", namespace)
+	}
+
+	deployments := map[string]string{}
+	for _, autoscaler := range autoscalers.Items {
+		target := autoscaler.Spec.ScaleTargetRef
+		if target.Kind == string(KindDeployment) {
+			deployments[target.Name] = autoscaler.Name
+		}
+	}
+
+	return deployments, nil
+}
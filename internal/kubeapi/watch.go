@@ -0,0 +1,206 @@
+package kubeapi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WorkloadRef names a workload whose card has to be rendered again.
+type WorkloadRef struct {
+	Kind WorkloadKind
+	Name string
+}
+
+// WorkloadChanges collects the workloads that changed since they were last drained. Changes of the same
+// workload coalesce, so a slow client never misses the last change of a workload, it only gets it later.
+type WorkloadChanges struct {
+	mu      sync.Mutex
+	pending map[WorkloadRef]struct{}
+	ready   chan struct{}
+	done    chan struct{}
+}
+
+func newWorkloadChanges() *WorkloadChanges {
+	return &WorkloadChanges{
+		pending: map[WorkloadRef]struct{}{},
+		ready:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Ready receives when there are changes to drain.
+func (w *WorkloadChanges) Ready() <-chan struct{} {
+	return w.ready
+}
+
+// Done is closed when the watch ended, the client should reconnect to watch again.
+func (w *WorkloadChanges) Done() <-chan struct{} {
+	return w.done
+}
+
+// Drain returns the workloads that changed, and forgets them.
+func (w *WorkloadChanges) Drain() []WorkloadRef {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	refs := make([]WorkloadRef, 0, len(w.pending))
+	for ref := range w.pending {
+		refs = append(refs, ref)
+	}
+	clear(w.pending)
+
+	return refs
+}
+
+func (w *WorkloadChanges) add(ref WorkloadRef) {
+	w.mu.Lock()
+	w.pending[ref] = struct{}{}
+	w.mu.Unlock()
+
+	select {
+	case w.ready <- struct{}{}:
+	default:
+		// Already signaled, the next Drain gets this change too
+	}
+}
+
+// WatchWorkloads collects the workloads of a namespace that changed, either themselves or one of their pods,
+// until ctx is done. A Cache with informers is watched through event handlers, a live one through watches.
+func WatchWorkloads(ctx context.Context, c *Cache, namespace string) (*WorkloadChanges, error) {
+	changes := newWorkloadChanges()
+	send := changes.add
+
+	if c.factory == nil {
+		return changes, c.watchLive(ctx, namespace, changes)
+	}
+
+	handler := cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj any) bool {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			object, ok := obj.(metav1.Object)
+			return ok && object.GetNamespace() == namespace
+		},
+		Handler: cache.ResourceEventHandlerDetailedFuncs{
+			// Objects already in the cache when the handler is added are on the page already
+			AddFunc: func(obj any, isInInitialList bool) {
+				if !isInInitialList {
+					c.notify(obj, send)
+				}
+			},
+			UpdateFunc: func(_, obj any) { c.notify(obj, send) },
+			DeleteFunc: func(obj any) { c.notify(obj, send) },
+		},
+	}
+
+	var registrations []cache.ResourceEventHandlerRegistration
+	for _, name := range []string{"deployments", "pods"} {
+		informer := c.informers[name]
+		registration, err := informer.AddEventHandler(handler)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to watch %s", name)
+		}
+		registrations = append(registrations, registration)
+	}
+
+	go func() {
+		<-ctx.Done()
+		c.informers["deployments"].RemoveEventHandler(registrations[0])
+		c.informers["pods"].RemoveEventHandler(registrations[1])
+	}()
+
+	return changes, nil
+}
+
+// watchLive closes the done channel of changes when a watch ends, so the client can reconnect and watch again.
+func (c *Cache) watchLive(ctx context.Context, namespace string, changes *WorkloadChanges) error {
+	deployments, err := c.clientSet.AppsV1().Deployments(namespace).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to watch deployments in namespace: %s", namespace)
+	}
+	pods, err := c.clientSet.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		deployments.Stop()
+		return errors.Wrapf(err, "failed to watch pods in namespace: %s", namespace)
+	}
+
+	go func() {
+		defer close(changes.done)
+		defer deployments.Stop()
+		defer pods.Stop()
+
+		for {
+			var event watch.Event
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok = <-deployments.ResultChan():
+			case event, ok = <-pods.ResultChan():
+			}
+			if !ok {
+				log.Warnf("Watch of namespace %s closed by the apiserver", namespace)
+				return
+			}
+			if event.Type != watch.Error {
+				c.notify(event.Object, changes.add)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// notify sends the workload of a changed Deployment or Pod, pods are traced back through their owner.
+func (c *Cache) notify(obj any, send func(WorkloadRef)) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	switch object := obj.(type) {
+	case *appsv1.Deployment:
+		send(WorkloadRef{Kind: KindDeployment, Name: object.Name})
+	case *corev1.Pod:
+		owner := metav1.GetControllerOf(object)
+		if owner == nil {
+			return
+		}
+		switch owner.Kind {
+		case "ReplicaSet":
+			if deployment := c.replicaSetDeployment(object.Namespace, owner.Name); deployment != "" {
+				send(WorkloadRef{Kind: KindDeployment, Name: deployment})
+			}
+		case string(KindStatefulSet), string(KindDaemonSet):
+			send(WorkloadRef{Kind: WorkloadKind(owner.Kind), Name: owner.Name})
+		}
+	}
+}
+
+// replicaSetDeployment finds the Deployment controlling a ReplicaSet.
+func (c *Cache) replicaSetDeployment(namespace, name string) string {
+	var replicaSet *appsv1.ReplicaSet
+	var err error
+	if c.synced("replicasets") {
+		replicaSet, err = c.replicaSets.ReplicaSets(namespace).Get(name)
+	} else {
+		replicaSet, err = c.clientSet.AppsV1().ReplicaSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return ""
+	}
+
+	if owner := metav1.GetControllerOf(replicaSet); owner != nil && owner.Kind == string(KindDeployment) {
+		return owner.Name
+	}
+
+	return ""
+}
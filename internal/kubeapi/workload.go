@@ -0,0 +1,222 @@
+package kubeapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// WorkloadKind is the kind of the object managing a set of pods.
+type WorkloadKind string
+
+const (
+	KindDeployment  WorkloadKind = "Deployment"
+	KindStatefulSet WorkloadKind = "StatefulSet"
+	KindDaemonSet   WorkloadKind = "DaemonSet"
+	// CronJobs are not listed as workloads, but their job template image can be updated like one
+	KindCronJob WorkloadKind = "CronJob"
+)
+
+var workloadKinds = []WorkloadKind{KindDeployment, KindStatefulSet, KindDaemonSet, KindCronJob}
+
+// ParseWorkloadKind parses a kind name case-insensitively, an empty kind is a Deployment.
+func ParseWorkloadKind(kind string) (WorkloadKind, error) {
+	if kind == "" {
+		return KindDeployment, nil
+	}
+
+	for _, k := range workloadKinds {
+		if strings.EqualFold(string(k), kind) {
+			return k, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported workload kind: %s", kind)
+}
+
+// Workload is the common view of Deployments, StatefulSets and DaemonSets.
+type Workload struct {
+	metav1.ObjectMeta
+
+	Kind            WorkloadKind
+	Selector        *metav1.LabelSelector
+	Template        corev1.PodTemplateSpec
+	DesiredReplicas int32
+	ReadyReplicas   int32
+}
+
+func deploymentWorkload(deployment appsv1.Deployment) Workload {
+	desired := int32(0)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	return Workload{
+		ObjectMeta:      deployment.ObjectMeta,
+		Kind:            KindDeployment,
+		Selector:        deployment.Spec.Selector,
+		Template:        deployment.Spec.Template,
+		DesiredReplicas: desired,
+		ReadyReplicas:   deployment.Status.ReadyReplicas,
+	}
+}
+
+func statefulSetWorkload(statefulSet appsv1.StatefulSet) Workload {
+	desired := int32(0)
+	if statefulSet.Spec.Replicas != nil {
+		desired = *statefulSet.Spec.Replicas
+	}
+
+	return Workload{
+		ObjectMeta:      statefulSet.ObjectMeta,
+		Kind:            KindStatefulSet,
+		Selector:        statefulSet.Spec.Selector,
+		Template:        statefulSet.Spec.Template,
+		DesiredReplicas: desired,
+		ReadyReplicas:   statefulSet.Status.ReadyReplicas,
+	}
+}
+
+func daemonSetWorkload(daemonSet appsv1.DaemonSet) Workload {
+	return Workload{
+		ObjectMeta:      daemonSet.ObjectMeta,
+		Kind:            KindDaemonSet,
+		Selector:        daemonSet.Spec.Selector,
+		Template:        daemonSet.Spec.Template,
+		DesiredReplicas: daemonSet.Status.DesiredNumberScheduled,
+		ReadyReplicas:   daemonSet.Status.NumberReady,
+	}
+}
+
+// ListWorkloads lists Deployments, StatefulSets and DaemonSets of a namespace, in that order.
+// StatefulSets and DaemonSets are skipped when the service account is not allowed to list them.
+// Deployments come from the cache, StatefulSets and DaemonSets are listed live. Only workloads matching selector
+// are listed, see ManagedSelector.
+func ListWorkloads(cache *Cache, namespace string, selector labels.Selector) ([]Workload, error) {
+	var workloads []Workload
+	clientSet := cache.ClientSet()
+	listOptions := metav1.ListOptions{LabelSelector: selector.String()}
+
+	deployments, err := cache.ListDeployments(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments {
+		workloads = append(workloads, deploymentWorkload(deployment))
+	}
+
+	statefulSets, err := clientSet.AppsV1().StatefulSets(namespace).List(context.TODO(), listOptions)
+	if apierrors.IsForbidden(err) {
+		log.Warnf("Failed to list statefulsets, permission not granted. %v", err)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to list statefulsets in namespace: %s", namespace)
+	} else {
+		for _, statefulSet := range statefulSets.Items {
+			workloads = append(workloads, statefulSetWorkload(statefulSet))
+		}
+	}
+
+	daemonSets, err := clientSet.AppsV1().DaemonSets(namespace).List(context.TODO(), listOptions)
+	if apierrors.IsForbidden(err) {
+		log.Warnf("Failed to list daemonsets, permission not granted. %v", err)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to list daemonsets in namespace: %s", namespace)
+	} else {
+		for _, daemonSet := range daemonSets.Items {
+			workloads = append(workloads, daemonSetWorkload(daemonSet))
+		}
+	}
+
+	return workloads, nil
+}
+
+// GetWorkload gets a Deployment from the cache, StatefulSets and DaemonSets are read live.
+func GetWorkload(cache *Cache, kind WorkloadKind, namespace, name string) (*Workload, error) {
+	var workload Workload
+	clientSet := cache.ClientSet()
+
+	switch kind {
+	case KindDeployment:
+		deployment, err := cache.GetDeployment(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		workload = deploymentWorkload(*deployment)
+	case KindStatefulSet:
+		statefulSet, err := clientSet.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get statefulset")
+		}
+		workload = statefulSetWorkload(*statefulSet)
+	case KindDaemonSet:
+		daemonSet, err := clientSet.AppsV1().DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get daemonset")
+		}
+		workload = daemonSetWorkload(*daemonSet)
+	default:
+		return nil, fmt.Errorf("unsupported workload kind: %s", kind)
+	}
+
+	return &workload, nil
+}
+
+// updatePodTemplate applies mutate to the pod template of a workload and updates it, retrying on conflicts.
+func updatePodTemplate(clientSet *kubernetes.Clientset, kind WorkloadKind, namespace, name string, mutate func(template *corev1.PodTemplateSpec) error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		switch kind {
+		case KindDeployment:
+			deployment, getErr := clientSet.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			if err := mutate(&deployment.Spec.Template); err != nil {
+				return err
+			}
+			_, updateErr := clientSet.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
+			return updateErr
+		case KindStatefulSet:
+			statefulSet, getErr := clientSet.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			if err := mutate(&statefulSet.Spec.Template); err != nil {
+				return err
+			}
+			_, updateErr := clientSet.AppsV1().StatefulSets(namespace).Update(context.TODO(), statefulSet, metav1.UpdateOptions{})
+			return updateErr
+		case KindDaemonSet:
+			daemonSet, getErr := clientSet.AppsV1().DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			if err := mutate(&daemonSet.Spec.Template); err != nil {
+				return err
+			}
+			_, updateErr := clientSet.AppsV1().DaemonSets(namespace).Update(context.TODO(), daemonSet, metav1.UpdateOptions{})
+			return updateErr
+		case KindCronJob:
+			cronJob, getErr := clientSet.BatchV1().CronJobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			if err := mutate(&cronJob.Spec.JobTemplate.Spec.Template); err != nil {
+				return err
+			}
+			_, updateErr := clientSet.BatchV1().CronJobs(namespace).Update(context.TODO(), cronJob, metav1.UpdateOptions{})
+			return updateErr
+		default:
+			return fmt.Errorf("unsupported workload kind: %s", kind)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+)
+
+// NamespaceFilter hides namespaces from upkube, e.g. kube-system, whatever the policy allows users.
+// Include and Exclude are glob patterns, a namespace must match an Include pattern, when there are any,
+// and no Exclude pattern.
+type NamespaceFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// NewNamespaceFilter checks the patterns of a filter.
+func NewNamespaceFilter(include, exclude []string) (NamespaceFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return NamespaceFilter{}, fmt.Errorf("invalid namespace pattern %q", pattern)
+		}
+	}
+
+	return NamespaceFilter{Include: include, Exclude: exclude}, nil
+}
+
+// Allows reports whether namespace is shown and can be acted on.
+func (f NamespaceFilter) Allows(namespace string) bool {
+	if len(f.Include) > 0 && !matchesAny(f.Include, namespace) {
+		return false
+	}
+
+	return !matchesAny(f.Exclude, namespace)
+}
+
+func matchesAny(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+
+	return false
+}
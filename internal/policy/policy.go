@@ -0,0 +1,182 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Verb is an action a user can take on workloads of a namespace.
+type Verb string
+
+const (
+	VerbView        Verb = "view"
+	VerbRestart     Verb = "restart"
+	VerbUpdateImage Verb = "update-image"
+	VerbScale       Verb = "scale"
+
+	// VerbAll matches every verb in a rule
+	VerbAll Verb = "*"
+)
+
+var knownVerbs = []Verb{VerbView, VerbRestart, VerbUpdateImage, VerbScale, VerbAll}
+
+// Subjects matches users by any of their emails, email domains or groups.
+type Subjects struct {
+	Emails  []string `json:"emails,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+// Rule grants verbs on namespaces to the users matching its subjects.
+// Namespaces are glob patterns, e.g. "staging-*".
+type Rule struct {
+	Subjects
+	Namespaces []string `json:"namespaces"`
+	Verbs      []Verb   `json:"verbs"`
+}
+
+// Policy decides what each user may do. Anything not granted by a rule is denied.
+// A nil Policy allows everything, which is the behaviour without a policy file, except managing API tokens.
+type Policy struct {
+	// Admins manage API tokens, when not set nobody does
+	Admins *Subjects `json:"admins,omitempty"`
+	Rules  []Rule    `json:"rules"`
+}
+
+func Load(filePath string) (*Policy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read policy file: %s", filePath)
+	}
+
+	var policy Policy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse policy file: %s", filePath)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid policy file: %s", filePath)
+	}
+
+	return &policy, nil
+}
+
+func (p *Policy) Validate() error {
+	if p.Admins != nil && p.Admins.empty() {
+		return fmt.Errorf("admins: at least one of emails, domains or groups is required")
+	}
+
+	for i, rule := range p.Rules {
+		if rule.empty() {
+			return fmt.Errorf("rule %d: at least one of emails, domains or groups is required", i)
+		}
+		if len(rule.Namespaces) == 0 {
+			return fmt.Errorf("rule %d: namespaces are required", i)
+		}
+		for _, pattern := range rule.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid namespace pattern %q", i, pattern)
+			}
+		}
+		if len(rule.Verbs) == 0 {
+			return fmt.Errorf("rule %d: verbs are required", i)
+		}
+		for _, verb := range rule.Verbs {
+			if !slices.Contains(knownVerbs, verb) {
+				return fmt.Errorf("rule %d: unknown verb %q", i, verb)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Allowed reports whether the user with email and groups may perform verb in namespace.
+func (p *Policy) Allowed(email string, groups []string, namespace string, verb Verb) bool {
+	if p == nil {
+		return true
+	}
+
+	for _, rule := range p.Rules {
+		if rule.matchesUser(email, groups) && rule.matchesNamespace(namespace) && rule.matchesVerb(verb) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAdmin reports whether the user with email and groups may manage API tokens.
+// Without a policy, or admins in it, nobody does, since tokens get past Cloudflare Access.
+func (p *Policy) IsAdmin(email string, groups []string) bool {
+	return p != nil && p.Admins != nil && p.Admins.matchesUser(email, groups)
+}
+
+// FilterNamespaces returns the namespaces the user may view.
+func (p *Policy) FilterNamespaces(email string, groups []string, namespaces []string) []string {
+	if p == nil {
+		return namespaces
+	}
+
+	var allowed []string
+	for _, namespace := range namespaces {
+		if p.Allowed(email, groups, namespace, VerbView) {
+			allowed = append(allowed, namespace)
+		}
+	}
+
+	return allowed
+}
+
+func (s Subjects) empty() bool {
+	return len(s.Emails) == 0 && len(s.Domains) == 0 && len(s.Groups) == 0
+}
+
+func (s Subjects) matchesUser(email string, groups []string) bool {
+	if email == "" {
+		return false
+	}
+
+	for _, e := range s.Emails {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+
+	if idx := strings.LastIndex(email, "@"); idx != -1 {
+		domain := email[idx+1:]
+		for _, d := range s.Domains {
+			if strings.EqualFold(d, domain) {
+				return true
+			}
+		}
+	}
+
+	for _, g := range s.Groups {
+		if slices.Contains(groups, g) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) matchesNamespace(namespace string) bool {
+	for _, pattern := range r.Namespaces {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) matchesVerb(verb Verb) bool {
+	return slices.Contains(r.Verbs, verb) || slices.Contains(r.Verbs, VerbAll)
+}
@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"slices"
+	"testing"
+)
+
+var testPolicy = &Policy{
+	Rules: []Rule{
+		{
+			Subjects:   Subjects{Emails: []string{"alice@corp.com"}},
+			Namespaces: []string{"*"},
+			Verbs:      []Verb{VerbAll},
+		},
+		{
+			Subjects:   Subjects{Domains: []string{"corp.com"}},
+			Namespaces: []string{"staging-*"},
+			Verbs:      []Verb{VerbView, VerbRestart},
+		},
+		{
+			Subjects:   Subjects{Groups: []string{"sre"}},
+			Namespaces: []string{"prod", "prod-*"},
+			Verbs:      []Verb{VerbView, VerbUpdateImage},
+		},
+	},
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *Policy
+		email     string
+		groups    []string
+		namespace string
+		verb      Verb
+		want      bool
+	}{
+		{name: "no policy", policy: nil, email: "anyone@else.io", namespace: "prod", verb: VerbScale, want: true},
+		{name: "email with any verb", policy: testPolicy, email: "alice@corp.com", namespace: "prod", verb: VerbScale, want: true},
+		{name: "email case", policy: testPolicy, email: "Alice@Corp.com", namespace: "kube-system", verb: VerbRestart, want: true},
+		{name: "domain glob", policy: testPolicy, email: "bob@corp.com", namespace: "staging-eu", verb: VerbRestart, want: true},
+		{name: "domain verb not granted", policy: testPolicy, email: "bob@corp.com", namespace: "staging-eu", verb: VerbUpdateImage, want: false},
+		{name: "domain namespace not matched", policy: testPolicy, email: "bob@corp.com", namespace: "staging", verb: VerbView, want: false},
+		{name: "domain is not a suffix match", policy: testPolicy, email: "eve@evilcorp.com", namespace: "staging-eu", verb: VerbView, want: false},
+		{name: "group", policy: testPolicy, email: "carol@contractor.io", groups: []string{"dev", "sre"}, namespace: "prod", verb: VerbUpdateImage, want: true},
+		{name: "group glob", policy: testPolicy, email: "carol@contractor.io", groups: []string{"sre"}, namespace: "prod-eu", verb: VerbView, want: true},
+		{name: "group not matched", policy: testPolicy, email: "carol@contractor.io", groups: []string{"dev"}, namespace: "prod", verb: VerbView, want: false},
+		{name: "no email with a group", policy: testPolicy, email: "", groups: []string{"sre"}, namespace: "prod", verb: VerbView, want: false},
+		{name: "no email nor group", policy: testPolicy, email: "", namespace: "staging-eu", verb: VerbView, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Allowed(tt.email, tt.groups, tt.namespace, tt.verb); got != tt.want {
+				t.Errorf("Allowed(%q, %v, %q, %q) = %v, want %v", tt.email, tt.groups, tt.namespace, tt.verb, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterNamespaces(t *testing.T) {
+	namespaces := []string{"default", "prod", "prod-eu", "staging", "staging-eu"}
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		email  string
+		groups []string
+		want   []string
+	}{
+		{name: "no policy", policy: nil, email: "anyone@else.io", want: namespaces},
+		{name: "email", policy: testPolicy, email: "alice@corp.com", want: namespaces},
+		{name: "domain", policy: testPolicy, email: "bob@corp.com", want: []string{"staging-eu"}},
+		{name: "domain and group", policy: testPolicy, email: "bob@corp.com", groups: []string{"sre"}, want: []string{"prod", "prod-eu", "staging-eu"}},
+		{name: "nothing", policy: testPolicy, email: "eve@evilcorp.com", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.FilterNamespaces(tt.email, tt.groups, namespaces); !slices.Equal(got, tt.want) {
+				t.Errorf("FilterNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	admins := &Policy{Admins: &Subjects{Emails: []string{"alice@corp.com"}, Groups: []string{"platform"}}}
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		email  string
+		groups []string
+		want   bool
+	}{
+		{name: "no policy", policy: nil, email: "alice@corp.com", want: false},
+		{name: "no admins", policy: testPolicy, email: "alice@corp.com", want: false},
+		{name: "email", policy: admins, email: "alice@corp.com", want: true},
+		{name: "group", policy: admins, email: "bob@corp.com", groups: []string{"platform"}, want: true},
+		{name: "not an admin", policy: admins, email: "bob@corp.com", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.IsAdmin(tt.email, tt.groups); got != tt.want {
+				t.Errorf("IsAdmin(%q, %v) = %v, want %v", tt.email, tt.groups, got, tt.want)
+			}
+		})
+	}
+}
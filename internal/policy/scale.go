@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ScaleBound limits the replicas a Deployment can be scaled to, in the namespaces matching a glob pattern.
+type ScaleBound struct {
+	Namespace string
+	Min       int32
+	Max       int32
+}
+
+// ScaleBounds are checked in order, the first bound matching a namespace applies.
+type ScaleBounds []ScaleBound
+
+// DefaultScaleBound applies to namespaces without a configured bound.
+var DefaultScaleBound = ScaleBound{Namespace: "*", Min: 0, Max: math.MaxInt32}
+
+// ParseScaleBounds parses a comma separated list of namespace=min:max, e.g. "prod-*=2:20,*=0:50".
+func ParseScaleBounds(value string) (ScaleBounds, error) {
+	var bounds ScaleBounds
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		namespace, limits, ok := strings.Cut(entry, "=")
+		minValue, maxValue, ok2 := strings.Cut(limits, ":")
+		if !ok || !ok2 || namespace == "" {
+			return nil, fmt.Errorf("invalid scale bound %q, expected namespace=min:max", entry)
+		}
+		if _, err := path.Match(namespace, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q in scale bound", namespace)
+		}
+
+		minReplicas, err := strconv.ParseInt(minValue, 10, 32)
+		if err != nil || minReplicas < 0 {
+			return nil, fmt.Errorf("invalid minimum replicas %q in scale bound %q", minValue, entry)
+		}
+		maxReplicas, err := strconv.ParseInt(maxValue, 10, 32)
+		if err != nil || maxReplicas < minReplicas {
+			return nil, fmt.Errorf("invalid maximum replicas %q in scale bound %q", maxValue, entry)
+		}
+
+		bounds = append(bounds, ScaleBound{
+			Namespace: namespace,
+			Min:       int32(minReplicas),
+			Max:       int32(maxReplicas),
+		})
+	}
+
+	return bounds, nil
+}
+
+// For returns the bound of a namespace, or DefaultScaleBound when none matches.
+func (b ScaleBounds) For(namespace string) ScaleBound {
+	for _, bound := range b {
+		if ok, _ := path.Match(bound.Namespace, namespace); ok {
+			return bound
+		}
+	}
+
+	return DefaultScaleBound
+}
+
+func (b ScaleBound) Check(replicas int32) error {
+	if replicas < b.Min || replicas > b.Max {
+		return fmt.Errorf("replicas must be between %d and %d in namespaces matching %q", b.Min, b.Max, b.Namespace)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var activityBucket = []byte("activity")
+
+const (
+	ActionRestart     = "restart"
+	ActionUpdateImage = "update-image"
+	ActionRequest     = "request-update-image"
+	ActionApprove     = "approve-update-image"
+	ActionReject      = "reject-update-image"
+	ActionRun         = "run"
+	ActionSuspend     = "suspend"
+	ActionResume      = "resume"
+	ActionRollback    = "rollback"
+	ActionScale       = "scale"
+	ActionDeletePod   = "delete-pod"
+	// A guarded image update failed to roll out, and the previous pod template was restored
+	ActionAutoRollback = "auto-rollback"
+	ActionCreateToken  = "create-token"
+	ActionRevokeToken  = "revoke-token"
+
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Activity is a single entry in the activity log.
+// Deployment is the name of the workload acted on, whose Kind is a Deployment when empty.
+// Cluster is empty for the single cluster upkube runs with, without a clusters file.
+type Activity struct {
+	ID         uint64    `json:"id"`
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Action     string    `json:"action"`
+	Cluster    string    `json:"cluster,omitempty"`
+	Namespace  string    `json:"namespace"`
+	Kind       string    `json:"kind,omitempty"`
+	Deployment string    `json:"deployment"`
+	Container  string    `json:"container,omitempty"`
+	Pod        string    `json:"pod,omitempty"`
+	Revision   int64     `json:"revision,omitempty"`
+	// Reason explains why upkube acted on its own, e.g. for an auto-rollback
+	Reason string `json:"reason,omitempty"`
+	// Token is the name of the API token created or revoked
+	Token string `json:"token,omitempty"`
+	// Replicas before and after a scale
+	OldReplicas *int32 `json:"oldReplicas,omitempty"`
+	NewReplicas *int32 `json:"newReplicas,omitempty"`
+	OldImage    string `json:"oldImage,omitempty"`
+	NewImage    string `json:"newImage,omitempty"`
+	Result      string `json:"result"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ActivityFilter narrows down ListActivity results, empty fields match everything.
+type ActivityFilter struct {
+	User       string
+	Namespace  string
+	Deployment string
+	Action     string
+	Result     string
+	Limit      int
+	// AllowedNamespace, when set, leaves out entries of the namespaces it rejects,
+	// before Limit applies, e.g. the ones the user may not view.
+	AllowedNamespace func(namespace string) bool
+}
+
+func (f ActivityFilter) matches(a Activity) bool {
+	return (f.User == "" || f.User == a.User) &&
+		(f.Namespace == "" || f.Namespace == a.Namespace) &&
+		(f.Deployment == "" || f.Deployment == a.Deployment) &&
+		(f.Action == "" || f.Action == a.Action) &&
+		(f.Result == "" || f.Result == a.Result) &&
+		(f.AllowedNamespace == nil || f.AllowedNamespace(a.Namespace))
+}
+
+func (s *Store) AddActivity(activity Activity) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(activityBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		activity.ID = id
+		if activity.Time.IsZero() {
+			activity.Time = time.Now()
+		}
+
+		data, err := json.Marshal(activity)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(itob(id), data)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to save activity")
+	}
+
+	return nil
+}
+
+// ListActivity returns the entries matching filter, newest first.
+func (s *Store) ListActivity(filter ActivityFilter) ([]Activity, error) {
+	var activities []Activity
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(activityBucket).Cursor()
+
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var activity Activity
+			if err := json.Unmarshal(v, &activity); err != nil {
+				return err
+			}
+			if !filter.matches(activity) {
+				continue
+			}
+
+			activities = append(activities, activity)
+			if filter.Limit > 0 && len(activities) >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list activity")
+	}
+
+	return activities, nil
+}
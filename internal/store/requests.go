@@ -0,0 +1,210 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var requestsBucket = []byte("requests")
+
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+	RequestRejected = "rejected"
+	RequestExpired  = "expired"
+	RequestFailed   = "failed"
+)
+
+var (
+	ErrRequestNotFound   = errors.New("change request not found")
+	ErrRequestNotPending = errors.New("change request is not pending")
+	ErrRequestExpired    = errors.New("change request has expired")
+	ErrSelfApproval      = errors.New("change request cannot be reviewed by its requester")
+)
+
+// ChangeRequest is an image update, or a rollback, waiting for a second user to approve it.
+// Cluster is empty for the single cluster upkube runs with, without a clusters file.
+type ChangeRequest struct {
+	ID          uint64    `json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	RequestedBy string    `json:"requestedBy"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Namespace   string    `json:"namespace"`
+	Kind        string    `json:"kind,omitempty"`
+	Deployment  string    `json:"deployment"`
+	Container   string    `json:"container"`
+	// Revision, when set, asks to rollback the Deployment to it instead of updating the image of Container
+	Revision   int64     `json:"revision,omitempty"`
+	OldImage   string    `json:"oldImage"`
+	NewImage   string    `json:"newImage"`
+	Status     string    `json:"status"`
+	ReviewedBy string    `json:"reviewedBy,omitempty"`
+	ReviewedAt time.Time `json:"reviewedAt,omitzero"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// IsExpired reports whether a pending request has passed its expiry.
+func (r ChangeRequest) IsExpired(now time.Time) bool {
+	return r.Status == RequestPending && now.After(r.ExpiresAt)
+}
+
+func (s *Store) CreateChangeRequest(request ChangeRequest) (ChangeRequest, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(requestsBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		request.ID = id
+		request.Status = RequestPending
+		if request.CreatedAt.IsZero() {
+			request.CreatedAt = time.Now()
+		}
+
+		return putChangeRequest(bucket, request)
+	})
+	if err != nil {
+		return ChangeRequest{}, errors.Wrap(err, "failed to create change request")
+	}
+
+	return request, nil
+}
+
+func (s *Store) GetChangeRequest(id uint64) (ChangeRequest, error) {
+	var request ChangeRequest
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(requestsBucket).Get(itob(id))
+		if data == nil {
+			return ErrRequestNotFound
+		}
+		return json.Unmarshal(data, &request)
+	})
+	if err != nil {
+		return ChangeRequest{}, err
+	}
+
+	if request.IsExpired(time.Now()) {
+		request.Status = RequestExpired
+	}
+
+	return request, nil
+}
+
+// ListChangeRequests returns change requests newest first, pending ones past their expiry are reported as expired.
+// An empty status matches every request.
+func (s *Store) ListChangeRequests(status string, limit int) ([]ChangeRequest, error) {
+	var requests []ChangeRequest
+	now := time.Now()
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(requestsBucket).Cursor()
+
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var request ChangeRequest
+			if err := json.Unmarshal(v, &request); err != nil {
+				return err
+			}
+			if request.IsExpired(now) {
+				request.Status = RequestExpired
+			}
+			if status != "" && request.Status != status {
+				continue
+			}
+
+			requests = append(requests, request)
+			if limit > 0 && len(requests) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list change requests")
+	}
+
+	return requests, nil
+}
+
+// ReviewChangeRequest approves or rejects a pending change request on behalf of reviewer.
+// The requester can never review their own request.
+func (s *Store) ReviewChangeRequest(id uint64, reviewer string, approve bool) (ChangeRequest, error) {
+	var request ChangeRequest
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(requestsBucket)
+
+		data := bucket.Get(itob(id))
+		if data == nil {
+			return ErrRequestNotFound
+		}
+		if err := json.Unmarshal(data, &request); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case request.IsExpired(now):
+			return ErrRequestExpired
+		case request.Status != RequestPending:
+			return ErrRequestNotPending
+		case strings.EqualFold(request.RequestedBy, reviewer):
+			return ErrSelfApproval
+		}
+
+		request.Status = RequestRejected
+		if approve {
+			request.Status = RequestApproved
+		}
+		request.ReviewedBy = reviewer
+		request.ReviewedAt = now
+
+		return putChangeRequest(bucket, request)
+	})
+	if err != nil {
+		return ChangeRequest{}, err
+	}
+
+	return request, nil
+}
+
+// FailChangeRequest marks an approved change request whose update could not be applied.
+func (s *Store) FailChangeRequest(id uint64, reason error) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(requestsBucket)
+
+		data := bucket.Get(itob(id))
+		if data == nil {
+			return ErrRequestNotFound
+		}
+		var request ChangeRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			return err
+		}
+
+		request.Status = RequestFailed
+		request.Error = reason.Error()
+
+		return putChangeRequest(bucket, request)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to update change request")
+	}
+
+	return nil
+}
+
+func putChangeRequest(bucket *bolt.Bucket, request ChangeRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(itob(request.ID), data)
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestReviewChangeRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  ChangeRequest
+		reviewer string
+		approve  bool
+		// reviewFirst reviews the request once before the reviewer does
+		reviewFirst bool
+		wantErr     error
+		wantStatus  string
+	}{
+		{
+			name:       "approve",
+			request:    ChangeRequest{RequestedBy: "alice@corp.com", ExpiresAt: time.Now().Add(time.Hour)},
+			reviewer:   "bob@corp.com",
+			approve:    true,
+			wantStatus: RequestApproved,
+		},
+		{
+			name:       "reject",
+			request:    ChangeRequest{RequestedBy: "alice@corp.com", ExpiresAt: time.Now().Add(time.Hour)},
+			reviewer:   "bob@corp.com",
+			wantStatus: RequestRejected,
+		},
+		{
+			name:     "self approval",
+			request:  ChangeRequest{RequestedBy: "alice@corp.com", ExpiresAt: time.Now().Add(time.Hour)},
+			reviewer: "alice@corp.com",
+			approve:  true,
+			wantErr:  ErrSelfApproval,
+		},
+		{
+			name:     "self approval with another case",
+			request:  ChangeRequest{RequestedBy: "alice@corp.com", ExpiresAt: time.Now().Add(time.Hour)},
+			reviewer: "Alice@Corp.com",
+			approve:  true,
+			wantErr:  ErrSelfApproval,
+		},
+		{
+			name:     "expired",
+			request:  ChangeRequest{RequestedBy: "alice@corp.com", ExpiresAt: time.Now().Add(-time.Minute)},
+			reviewer: "bob@corp.com",
+			approve:  true,
+			wantErr:  ErrRequestExpired,
+		},
+		{
+			name:        "already reviewed",
+			request:     ChangeRequest{RequestedBy: "alice@corp.com", ExpiresAt: time.Now().Add(time.Hour)},
+			reviewer:    "bob@corp.com",
+			approve:     true,
+			reviewFirst: true,
+			wantErr:     ErrRequestNotPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestStore(t)
+
+			request, err := s.CreateChangeRequest(tt.request)
+			if err != nil {
+				t.Fatalf("failed to create change request: %v", err)
+			}
+			if tt.reviewFirst {
+				if _, err := s.ReviewChangeRequest(request.ID, "carol@corp.com", false); err != nil {
+					t.Fatalf("failed to review change request: %v", err)
+				}
+			}
+
+			reviewed, err := s.ReviewChangeRequest(request.ID, tt.reviewer, tt.approve)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReviewChangeRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if reviewed.Status != tt.wantStatus || reviewed.ReviewedBy != tt.reviewer {
+				t.Errorf("ReviewChangeRequest() = status %q by %q, want %q by %q", reviewed.Status, reviewed.ReviewedBy, tt.wantStatus, tt.reviewer)
+			}
+
+			stored, err := s.GetChangeRequest(request.ID)
+			if err != nil {
+				t.Fatalf("failed to get change request: %v", err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("stored status = %q, want %q", stored.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestReviewChangeRequestNotFound(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.ReviewChangeRequest(42, "bob@corp.com", true); !errors.Is(err, ErrRequestNotFound) {
+		t.Fatalf("ReviewChangeRequest() error = %v, want %v", err, ErrRequestNotFound)
+	}
+}
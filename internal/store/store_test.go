@@ -0,0 +1,19 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// openTestStore opens a store in a temporary directory, closed when the test ends.
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "upkube.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var tokensBucket = []byte("tokens")
+
+// tokenHashesBucket indexes tokens by their hash, so verifying a token is a single read.
+var tokenHashesBucket = []byte("token-hashes")
+
+// TokenPrefix starts every API token, so a leaked one is easy to recognise and search for.
+const TokenPrefix = "upk_"
+
+var (
+	ErrTokenNotFound = errors.New("api token not found")
+	// Names attribute token calls in the activity log, so they are never reused, even after a revoke
+	ErrTokenNameTaken = errors.New("an api token with this name already exists")
+	ErrTokenInvalid   = errors.New("api token is invalid, revoked or expired")
+)
+
+// APIToken lets a script act on the API without a Cloudflare Access login, within its scope.
+// Only the SHA-256 hash of the token is stored, the token itself is shown once when it is created.
+type APIToken struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+	// Glob patterns of the namespaces and deployments (workload names) the token can act on
+	Namespaces  []string `json:"namespaces"`
+	Deployments []string `json:"deployments"`
+	// Verbs of the authorization policy, "*" for all of them
+	Verbs      []string  `json:"verbs"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitzero"`
+	RevokedBy  string    `json:"revokedBy,omitempty"`
+	RevokedAt  time.Time `json:"revokedAt,omitzero"`
+}
+
+func (t APIToken) IsRevoked() bool {
+	return !t.RevokedAt.IsZero()
+}
+
+func (t APIToken) IsExpired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt)
+}
+
+// Allows reports whether the token may perform verb on the named workload in namespace.
+// An empty name checks the namespace only, e.g. to list its workloads.
+func (t APIToken) Allows(namespace, name, verb string) bool {
+	return matchesAny(t.Namespaces, namespace) &&
+		(name == "" || matchesAny(t.Deployments, name)) &&
+		(slices.Contains(t.Verbs, verb) || slices.Contains(t.Verbs, "*"))
+}
+
+func matchesAny(patterns []string, value string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		ok, _ := path.Match(pattern, value)
+		return ok
+	})
+}
+
+// HashToken is how tokens are stored and looked up.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken generates a new token for the given scope, and returns it along with its stored record.
+func (s *Store) CreateAPIToken(token APIToken) (string, APIToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIToken{}, errors.Wrap(err, "failed to generate api token")
+	}
+	plain := TokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	token.Hash = HashToken(plain)
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokensBucket)
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var existing APIToken
+			if err := json.Unmarshal(v, &existing); err != nil {
+				return err
+			}
+			if existing.Name == token.Name {
+				return ErrTokenNameTaken
+			}
+		}
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		token.ID = id
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+
+		if err := tx.Bucket(tokenHashesBucket).Put([]byte(token.Hash), itob(token.ID)); err != nil {
+			return err
+		}
+
+		return putAPIToken(bucket, token)
+	})
+	if errors.Is(err, ErrTokenNameTaken) {
+		return "", APIToken{}, err
+	} else if err != nil {
+		return "", APIToken{}, errors.Wrap(err, "failed to create api token")
+	}
+
+	return plain, token, nil
+}
+
+// ListAPITokens returns every token, newest first, revoked ones included.
+func (s *Store) ListAPITokens() ([]APIToken, error) {
+	var tokens []APIToken
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(tokensBucket).Cursor()
+
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var token APIToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			tokens = append(tokens, token)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list api tokens")
+	}
+
+	return tokens, nil
+}
+
+// VerifyAPIToken finds the valid token matching plain, and records it as used.
+// It only writes when the last use is stale, so API calls do not wait on each other.
+func (s *Store) VerifyAPIToken(plain string) (APIToken, error) {
+	if !strings.HasPrefix(plain, TokenPrefix) {
+		return APIToken{}, ErrTokenInvalid
+	}
+	hash := []byte(HashToken(plain))
+
+	var token APIToken
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(tokenHashesBucket).Get(hash)
+		if id == nil {
+			return nil
+		}
+		data := tx.Bucket(tokensBucket).Get(id)
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &token)
+	})
+	if err != nil {
+		return APIToken{}, errors.Wrap(err, "failed to verify api token")
+	}
+
+	now := time.Now()
+	if !found || token.IsRevoked() || token.IsExpired(now) {
+		return APIToken{}, ErrTokenInvalid
+	}
+
+	// Once a minute is precise enough, and saves a write on every call
+	if now.Sub(token.LastUsedAt) < time.Minute {
+		return token, nil
+	}
+	token.LastUsedAt = now
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokensBucket)
+
+		// Read again, the token may have been revoked since
+		var stored APIToken
+		data := bucket.Get(itob(token.ID))
+		if data == nil {
+			return ErrTokenInvalid
+		}
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+		if stored.IsRevoked() || stored.IsExpired(now) {
+			return ErrTokenInvalid
+		}
+		stored.LastUsedAt = now
+		token = stored
+
+		return putAPIToken(bucket, stored)
+	})
+	if errors.Is(err, ErrTokenInvalid) {
+		return APIToken{}, err
+	} else if err != nil {
+		// The token is valid, failing to record its use does not fail the call
+		log.Warnf("Failed to record the use of api token %s: %v", token.Name, err)
+	}
+
+	return token, nil
+}
+
+// RevokeAPIToken stops a token from being accepted, it is kept for the record.
+func (s *Store) RevokeAPIToken(id uint64, revokedBy string) (APIToken, error) {
+	var token APIToken
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokensBucket)
+
+		data := bucket.Get(itob(id))
+		if data == nil {
+			return ErrTokenNotFound
+		}
+		if err := json.Unmarshal(data, &token); err != nil {
+			return err
+		}
+		if token.IsRevoked() {
+			return nil
+		}
+
+		token.RevokedBy = revokedBy
+		token.RevokedAt = time.Now()
+
+		return putAPIToken(bucket, token)
+	})
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	return token, nil
+}
+
+func putAPIToken(bucket *bolt.Bucket, token APIToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(itob(token.ID), data)
+}
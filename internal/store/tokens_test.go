@@ -0,0 +1,98 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestAPITokenAllows(t *testing.T) {
+	token := APIToken{
+		Namespaces:  []string{"staging-*", "prod"},
+		Deployments: []string{"web", "api-*"},
+		Verbs:       []string{"view", "restart"},
+	}
+
+	tests := []struct {
+		name      string
+		token     APIToken
+		namespace string
+		workload  string
+		verb      string
+		want      bool
+	}{
+		{name: "namespace and workload", token: token, namespace: "prod", workload: "web", verb: "restart", want: true},
+		{name: "globs", token: token, namespace: "staging-eu", workload: "api-worker", verb: "view", want: true},
+		{name: "namespace only", token: token, namespace: "prod", verb: "view", want: true},
+		{name: "namespace not matched", token: token, namespace: "default", workload: "web", verb: "view", want: false},
+		{name: "workload not matched", token: token, namespace: "prod", workload: "db", verb: "view", want: false},
+		{name: "verb not granted", token: token, namespace: "prod", workload: "web", verb: "update-image", want: false},
+		{name: "any verb", token: APIToken{Namespaces: []string{"*"}, Deployments: []string{"*"}, Verbs: []string{"*"}}, namespace: "prod", workload: "web", verb: "scale", want: true},
+		{name: "empty scope", token: APIToken{}, namespace: "prod", workload: "web", verb: "view", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Allows(tt.namespace, tt.workload, tt.verb); got != tt.want {
+				t.Errorf("Allows(%q, %q, %q) = %v, want %v", tt.namespace, tt.workload, tt.verb, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAPIToken(t *testing.T) {
+	s := openTestStore(t)
+
+	create := func(token APIToken) (string, APIToken) {
+		t.Helper()
+		plain, created, err := s.CreateAPIToken(token)
+		if err != nil {
+			t.Fatalf("failed to create api token: %v", err)
+		}
+		return plain, created
+	}
+
+	valid, validToken := create(APIToken{Name: "valid"})
+	expired, _ := create(APIToken{Name: "expired", ExpiresAt: time.Now().Add(-time.Minute)})
+	revoked, revokedToken := create(APIToken{Name: "revoked"})
+	if _, err := s.RevokeAPIToken(revokedToken.ID, "admin@corp.com"); err != nil {
+		t.Fatalf("failed to revoke api token: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		plain    string
+		wantName string
+		wantErr  error
+	}{
+		{name: "valid", plain: valid, wantName: "valid"},
+		{name: "expired", plain: expired, wantErr: ErrTokenInvalid},
+		{name: "revoked", plain: revoked, wantErr: ErrTokenInvalid},
+		{name: "wrong prefix", plain: "ghp_" + valid[len(TokenPrefix):], wantErr: ErrTokenInvalid},
+		{name: "unknown", plain: TokenPrefix + "unknown", wantErr: ErrTokenInvalid},
+		{name: "empty", plain: "", wantErr: ErrTokenInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := s.VerifyAPIToken(tt.plain)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAPIToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if token.Name != tt.wantName {
+				t.Errorf("VerifyAPIToken() = %q, want %q", token.Name, tt.wantName)
+			}
+		})
+	}
+
+	tokens, err := s.ListAPITokens()
+	if err != nil {
+		t.Fatalf("failed to list api tokens: %v", err)
+	}
+	for _, token := range tokens {
+		if token.ID == validToken.ID && token.LastUsedAt.IsZero() {
+			t.Errorf("LastUsedAt of a verified token is not recorded")
+		}
+	}
+}
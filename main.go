@@ -19,19 +19,64 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/charmbracelet/log"
+	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/kunalsin9h/upkube/internal/api"
+	"github.com/kunalsin9h/upkube/internal/config"
 	"github.com/kunalsin9h/upkube/internal/kubeapi"
+	"github.com/kunalsin9h/upkube/internal/store"
 )
 
 var (
+	// YAML or TOML file with the settings below, env vars override it
+	UPKUBE_CONFIG_FILE = ""
+
 	UPKUBE_HOST = "127.0.0.1"
 	UPKUBE_PORT = "8080"
 	UPKUBE_ENV  = "DEV" // or "PROD" based on your environment
+	// In DEV, the kubeconfig context and master URL to connect with, kubeconfig files are read from KUBECONFIG
+	UPKUBE_KUBE_CONTEXT = "" // defaults to the current context
+	UPKUBE_MASTER_URL   = "" // defaults to the server of the context
+	// Activity logs are stored here, mount a volume on it in production
+	UPKUBE_DB_PATH = "upkube.db"
+	// Comma separated namespaces, where image updates need approval from a second user
+	UPKUBE_PROTECTED_NAMESPACES = ""
+	UPKUBE_REQUEST_TTL          = "24h"
+	// Cloudflare Access application, when set every request token is validated
+	UPKUBE_CF_TEAM_DOMAIN = "" // e.g. https://<team>.cloudflareaccess.com
+	UPKUBE_CF_AUDIENCE    = "" // Application Audience (AUD) tag
+	UPKUBE_CF_CERTS_URL   = "" // defaults to <team domain>/cdn-cgi/access/certs
+	// YAML file with the namespaces and verbs allowed per user, when empty everything is allowed
+	UPKUBE_POLICY_FILE = ""
+	// When "true", Kubernetes calls impersonate the authenticated user, instead of using the service account
+	UPKUBE_IMPERSONATE = "false"
+	// Comma separated namespace=min:max replicas, e.g. "prod-*=2:20,*=0:50", namespaces are glob patterns
+	UPKUBE_SCALE_BOUNDS = ""
+
+	UPKUBE_GUARDED_NAMESPACES = ""
+	UPKUBE_GUARD_WINDOW       = "5m"
+
+	UPKUBE_REGISTRY_WEBHOOK_SECRET = "" // enables POST /hooks/registry
+	// Accept the secret itself as the Authorization header, for registries that can not sign their webhooks
+	UPKUBE_REGISTRY_WEBHOOK_PLAIN_SECRET = "false"
+
+	// Comma separated namespaces shown, instead of listing them, for service accounts that can not list namespaces
+	UPKUBE_NAMESPACES = ""
+	// Comma separated glob patterns of the namespaces shown, e.g. "team-*", and hidden, e.g. "kube-*"
+	UPKUBE_INCLUDE_NAMESPACES = ""
+	UPKUBE_EXCLUDE_NAMESPACES = ""
+	// When "true", only workloads labeled upkube.io/managed=true are shown and acted on
+	UPKUBE_MANAGED_ONLY = "false"
+
+	UPKUBE_CLUSTERS_FILE = "" // manage several clusters, instead of the one of UPKUBE_ENV
 )
 
 func init() {
+	if os.Getenv("UPKUBE_CONFIG_FILE") != "" {
+		UPKUBE_CONFIG_FILE = os.Getenv("UPKUBE_CONFIG_FILE")
+	}
 	if os.Getenv("UPKUBE_HOST") != "" {
 		UPKUBE_HOST = os.Getenv("UPKUBE_HOST")
 	}
@@ -41,19 +86,177 @@ func init() {
 	if os.Getenv("UPKUBE_ENV") != "" {
 		UPKUBE_ENV = os.Getenv("UPKUBE_ENV")
 	}
+	if os.Getenv("UPKUBE_KUBE_CONTEXT") != "" {
+		UPKUBE_KUBE_CONTEXT = os.Getenv("UPKUBE_KUBE_CONTEXT")
+	}
+	if os.Getenv("UPKUBE_MASTER_URL") != "" {
+		UPKUBE_MASTER_URL = os.Getenv("UPKUBE_MASTER_URL")
+	}
+	if os.Getenv("UPKUBE_DB_PATH") != "" {
+		UPKUBE_DB_PATH = os.Getenv("UPKUBE_DB_PATH")
+	}
+	if os.Getenv("UPKUBE_PROTECTED_NAMESPACES") != "" {
+		UPKUBE_PROTECTED_NAMESPACES = os.Getenv("UPKUBE_PROTECTED_NAMESPACES")
+	}
+	if os.Getenv("UPKUBE_REQUEST_TTL") != "" {
+		UPKUBE_REQUEST_TTL = os.Getenv("UPKUBE_REQUEST_TTL")
+	}
+	if os.Getenv("UPKUBE_CF_TEAM_DOMAIN") != "" {
+		UPKUBE_CF_TEAM_DOMAIN = os.Getenv("UPKUBE_CF_TEAM_DOMAIN")
+	}
+	if os.Getenv("UPKUBE_CF_AUDIENCE") != "" {
+		UPKUBE_CF_AUDIENCE = os.Getenv("UPKUBE_CF_AUDIENCE")
+	}
+	if os.Getenv("UPKUBE_CF_CERTS_URL") != "" {
+		UPKUBE_CF_CERTS_URL = os.Getenv("UPKUBE_CF_CERTS_URL")
+	}
+	if os.Getenv("UPKUBE_POLICY_FILE") != "" {
+		UPKUBE_POLICY_FILE = os.Getenv("UPKUBE_POLICY_FILE")
+	}
+	if os.Getenv("UPKUBE_IMPERSONATE") != "" {
+		UPKUBE_IMPERSONATE = os.Getenv("UPKUBE_IMPERSONATE")
+	}
+	if os.Getenv("UPKUBE_SCALE_BOUNDS") != "" {
+		UPKUBE_SCALE_BOUNDS = os.Getenv("UPKUBE_SCALE_BOUNDS")
+	}
+	if os.Getenv("UPKUBE_GUARDED_NAMESPACES") != "" {
+		UPKUBE_GUARDED_NAMESPACES = os.Getenv("UPKUBE_GUARDED_NAMESPACES")
+	}
+	if os.Getenv("UPKUBE_GUARD_WINDOW") != "" {
+		UPKUBE_GUARD_WINDOW = os.Getenv("UPKUBE_GUARD_WINDOW")
+	}
+	if os.Getenv("UPKUBE_REGISTRY_WEBHOOK_SECRET") != "" {
+		UPKUBE_REGISTRY_WEBHOOK_SECRET = os.Getenv("UPKUBE_REGISTRY_WEBHOOK_SECRET")
+	}
+	if os.Getenv("UPKUBE_REGISTRY_WEBHOOK_PLAIN_SECRET") != "" {
+		UPKUBE_REGISTRY_WEBHOOK_PLAIN_SECRET = os.Getenv("UPKUBE_REGISTRY_WEBHOOK_PLAIN_SECRET")
+	}
+	if os.Getenv("UPKUBE_CLUSTERS_FILE") != "" {
+		UPKUBE_CLUSTERS_FILE = os.Getenv("UPKUBE_CLUSTERS_FILE")
+	}
+	if os.Getenv("UPKUBE_NAMESPACES") != "" {
+		UPKUBE_NAMESPACES = os.Getenv("UPKUBE_NAMESPACES")
+	}
+	if os.Getenv("UPKUBE_INCLUDE_NAMESPACES") != "" {
+		UPKUBE_INCLUDE_NAMESPACES = os.Getenv("UPKUBE_INCLUDE_NAMESPACES")
+	}
+	if os.Getenv("UPKUBE_EXCLUDE_NAMESPACES") != "" {
+		UPKUBE_EXCLUDE_NAMESPACES = os.Getenv("UPKUBE_EXCLUDE_NAMESPACES")
+	}
+	if os.Getenv("UPKUBE_MANAGED_ONLY") != "" {
+		UPKUBE_MANAGED_ONLY = os.Getenv("UPKUBE_MANAGED_ONLY")
+	}
+}
+
+// splitList splits a comma separated env value, ignoring empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newClusters connects to the clusters of UPKUBE_CLUSTERS_FILE or the config file, or else to the single cluster of UPKUBE_ENV.
+func newClusters(file *config.File) ([]*kubeapi.Cluster, error) {
+	if UPKUBE_CLUSTERS_FILE == "" && len(file.Clusters) == 0 {
+		restConfig, contextName, err := kubeapi.NewRestConfig(UPKUBE_ENV, kubeapi.KubeconfigOptions{Context: UPKUBE_KUBE_CONTEXT, MasterURL: UPKUBE_MASTER_URL})
+		if err != nil {
+			return nil, err
+		}
+		clientSet, err := kubeapi.NewClientSet(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		return []*kubeapi.Cluster{{Context: contextName, RestConfig: restConfig, ClientSet: clientSet}}, nil
+	}
+
+	configs := file.Clusters
+	if UPKUBE_CLUSTERS_FILE != "" {
+		var err error
+		if configs, err = kubeapi.LoadClusterConfigs(UPKUBE_CLUSTERS_FILE); err != nil {
+			return nil, err
+		}
+	}
+
+	var clusters []*kubeapi.Cluster
+	for _, config := range configs {
+		cluster, err := kubeapi.NewCluster(config)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, nil
 }
 
 func main() {
-	// Version and Go build version info
-	fmt.Println(upkubeInfoMessage())
+	configFile := &config.File{}
+	if UPKUBE_CONFIG_FILE != "" {
+		var err error
+		if configFile, err = config.Load(UPKUBE_CONFIG_FILE); err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+	}
+	applyConfigFile(configFile)
 
-	clientSet, err := kubeapi.NewClientSet(UPKUBE_ENV)
+	clusters, err := newClusters(configFile)
 	if err != nil {
 		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
 
-	serverConfig := api.NewServiceConfig(clientSet,
-		api.WithHost(UPKUBE_HOST), api.WithPort(UPKUBE_PORT), api.WithEnv(UPKUBE_ENV))
+	// Version, Go build version and the clusters connected to
+	fmt.Println(upkubeInfoMessage(clusters))
+
+	settings, err := newSettings(configFile)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	db, err := store.Open(UPKUBE_DB_PATH)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var accessVerifier *oidc.IDTokenVerifier
+	if UPKUBE_CF_TEAM_DOMAIN != "" && UPKUBE_CF_AUDIENCE != "" {
+		accessVerifier = api.NewAccessVerifier(UPKUBE_CF_TEAM_DOMAIN, UPKUBE_CF_AUDIENCE, UPKUBE_CF_CERTS_URL)
+	} else if strings.EqualFold(UPKUBE_ENV, "PROD") {
+		log.Warn("UPKUBE_CF_TEAM_DOMAIN and UPKUBE_CF_AUDIENCE are not set, trusting the Cf-Access-Authenticated-User-Email header without validation")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// Pages read from informers, instead of listing on every render.
+	// Impersonated users read live, since they may not see what the service account sees.
+	impersonate := strings.EqualFold(UPKUBE_IMPERSONATE, "true")
+	if !impersonate {
+		for _, cluster := range clusters {
+			cluster.Cache = kubeapi.NewCache(cluster.ClientSet)
+			cluster.Cache.Start(stopCh)
+		}
+	}
+
+	serverConfig := api.NewServiceConfig(clusters,
+		api.WithHost(UPKUBE_HOST), api.WithPort(UPKUBE_PORT), api.WithEnv(UPKUBE_ENV), api.WithStore(db),
+		api.WithProtectedNamespaces(settings.ProtectedNamespaces), api.WithRequestTTL(settings.RequestTTL),
+		api.WithAccessVerifier(accessVerifier), api.WithPolicy(settings.Policy),
+		api.WithImpersonation(impersonate),
+		api.WithScaleBounds(settings.ScaleBounds), api.WithGuardedUpdates(settings.GuardedNamespaces, settings.GuardWindow),
+		api.WithRegistryWebhook(settings.RegistryWebhookSecret, settings.RegistryWebhookPlainSecret),
+		api.WithNamespaces(settings.Namespaces, settings.NamespaceFilter),
+		api.WithManagedOnly(settings.ManagedOnly))
+
+	if UPKUBE_CONFIG_FILE != "" {
+		if err := watchConfigFile(serverConfig, configFile, stopCh); err != nil {
+			log.Warnf("Config file changes are not reloaded: %v", err)
+		}
+	}
 
 	log.Infof("Starting Upkube server on %s:%s in %s environment", serverConfig.Host, serverConfig.Port, serverConfig.Env)
 	if err := api.StartHttpServer(serverConfig); err != nil {
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"strings"
+
 	"github.com/fatih/color"
+	"github.com/kunalsin9h/upkube/internal/kubeapi"
 )
 
 var version = "dev"
@@ -24,6 +27,32 @@ func upkubeTextArt() string {
 `
 }
 
-func upkubeInfoMessage() string {
-	return fmt.Sprintf("%s\tversion %s, build with Go %s\n", yellowBold(upkubeTextArt()), whiteBold(version), whiteBold(goVersion))
+func upkubeInfoMessage(clusters []*kubeapi.Cluster) string {
+	message := fmt.Sprintf("%s\tversion %s, build with Go %s\n", yellowBold(upkubeTextArt()), whiteBold(version), whiteBold(goVersion))
+
+	for _, cluster := range clusters {
+		message += "\t" + clusterInfoMessage(cluster) + "\n"
+	}
+
+	return message
+}
+
+// clusterInfoMessage tells which cluster upkube is connected to, clusters that look like production stand out in red.
+func clusterInfoMessage(cluster *kubeapi.Cluster) string {
+	target := "in-cluster config"
+	if cluster.Context != "" {
+		target = "context " + cluster.Context
+	}
+
+	target = fmt.Sprintf("%s (%s)", target, cluster.RestConfig.Host)
+	if cluster.Name != "" {
+		target = cluster.Name + ": " + target
+	}
+
+	highlight := cyanBold
+	if strings.Contains(strings.ToLower(target), "prod") {
+		highlight = redBold
+	}
+
+	return fmt.Sprintf("connected to %s", highlight(target))
 }